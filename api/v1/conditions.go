@@ -0,0 +1,29 @@
+/*
+Copyright 2023 clementreiffers.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// Condition types reported in the status of every kind of the group.
+const (
+	// ConditionReady is true once the resource and everything it generates are up to date.
+	ConditionReady = "Ready"
+	// ConditionBuilding is true while an image is being built for the resource.
+	ConditionBuilding = "Building"
+	// ConditionProgressing is true while generated resources are being rolled out.
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded is true when the last reconciliation failed.
+	ConditionDegraded = "Degraded"
+)
@@ -23,22 +23,195 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// JobBuilderScript is a script built into the image and the port serving it.
+type JobBuilderScript struct {
+	ScriptName string `json:"scriptName"`
+	Url        string `json:"url"`
+	Port       int32  `json:"port"`
+	// Sha256 is the expected digest of the script, the build fails when the
+	// fetched script does not match it.
+	//+optional
+	Sha256 string `json:"sha256,omitempty"`
+	// CompatibilityDate is the workerd compatibility date of the script, as
+	// YYYY-MM-DD.
+	//+optional
+	//+kubebuilder:validation:Pattern=`^\d{4}-\d{2}-\d{2}$`
+	CompatibilityDate string `json:"compatibilityDate,omitempty"`
+	// MainModule is the path of the entry point of the script.
+	//+optional
+	MainModule string `json:"mainModule,omitempty"`
+	// Bindings expose environment variables of the runtime pod to the script.
+	//+optional
+	Bindings []JobBuilderBinding `json:"bindings,omitempty"`
+}
+
+// JobBuilderBinding binds an environment variable of the runtime pod to a
+// script.
+type JobBuilderBinding struct {
+	Name            string `json:"name"`
+	FromEnvironment string `json:"fromEnvironment"`
+}
+
+// JobBuilderBackend is the tool building the image of a JobBuilder.
+// +kubebuilder:validation:Enum=Kaniko;BuildKit;Prebuilt
+type JobBuilderBackend string
+
+const (
+	// BackendKaniko builds the image with kaniko.
+	BackendKaniko JobBuilderBackend = "Kaniko"
+	// BackendBuildKit builds the image with a rootless BuildKit.
+	BackendBuildKit JobBuilderBackend = "BuildKit"
+	// BackendPrebuilt builds nothing, the target image was pushed beforehand
+	// and only its digest is resolved.
+	BackendPrebuilt JobBuilderBackend = "Prebuilt"
+)
+
+// BuildCredentials name the secrets used by the build Job, in the namespace of
+// the Job. Empty fields fall back to the secrets of the operator configuration.
+type BuildCredentials struct {
+	// RegistrySecretRef is a kubernetes.io/dockerconfigjson secret pushing the
+	// built image.
+	//+optional
+	RegistrySecretRef string `json:"registrySecretRef,omitempty"`
+	// StorageSecretRef is a secret holding the AWS credentials file, under the
+	// credentials key, fetching the scripts from S3.
+	//+optional
+	StorageSecretRef string `json:"storageSecretRef,omitempty"`
+}
+
 // JobBuilderSpec defines the desired state of JobBuilder
 type JobBuilderSpec struct {
-	ScriptUrls       []string `json:"scriptUrls"`
-	TargetImage      string   `json:"targetImage"`
-	WorkerBundleName string   `json:"workerBundleName"`
-	ScriptNames      []string `json:"scriptNames"`
+	// Scripts are the scripts to build, sorted by port.
+	//+listType=map
+	//+listMapKey=scriptName
+	Scripts          []JobBuilderScript `json:"scripts"`
+	TargetImage      string             `json:"targetImage"`
+	WorkerBundleName string             `json:"workerBundleName"`
+	// SecretRefs maps script names to the secret exposed to them.
+	//+optional
+	SecretRefs map[string]string `json:"secretRefs,omitempty"`
+	// BuildTimeout overrides the build timeout of the operator for this
+	// JobBuilder, it bounds every attempt of the build along with the delays
+	// between them.
+	//+optional
+	BuildTimeout *metav1.Duration `json:"buildTimeout,omitempty"`
+	// MaxAttempts is the number of build Jobs run before the JobBuilder fails,
+	// only transient failures, such as registry or S3 errors, are retried.
+	//+kubebuilder:default=3
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	MaxAttempts *int32 `json:"maxAttempts,omitempty"`
+	// ActiveDeadlineSeconds bounds the duration of each build Job.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// Builder overrides the builder of the operator for this JobBuilder.
+	//+optional
+	Builder JobBuilderBackend `json:"builder,omitempty"`
+	// Credentials override the secrets of the operator for this JobBuilder.
+	//+optional
+	Credentials BuildCredentials `json:"credentials,omitempty"`
+	// Priority orders the builds waiting for a free slot, higher first, then
+	// oldest first.
+	//+optional
+	Priority int32 `json:"priority,omitempty"`
+}
+
+// JobBuilderPhase is the build state of a JobBuilder.
+// +kubebuilder:validation:Enum=Pending;Building;Succeeded;Failed;Superseded
+type JobBuilderPhase string
+
+const (
+	// JobBuilderPending means the build Job has not been created yet.
+	JobBuilderPending JobBuilderPhase = "Pending"
+	// JobBuilderBuilding means the build Job is running.
+	JobBuilderBuilding JobBuilderPhase = "Building"
+	// JobBuilderSucceeded means the image was pushed and the WorkerBundle updated.
+	JobBuilderSucceeded JobBuilderPhase = "Succeeded"
+	// JobBuilderFailed means the build Job failed, was deleted or timed out.
+	JobBuilderFailed JobBuilderPhase = "Failed"
+	// JobBuilderSuperseded means the build was dropped while queued in favor
+	// of a newer build of the same bundle.
+	JobBuilderSuperseded JobBuilderPhase = "Superseded"
+)
+
+// JobBuilderCacheStatus summarizes the use of the layer cache by a build.
+type JobBuilderCacheStatus struct {
+	// Hits is the number of layers reused from the cache.
+	Hits int32 `json:"hits"`
+	// Misses is the number of layers built and pushed to the cache.
+	Misses int32 `json:"misses"`
+}
+
+// JobBuilderContainerStatus describes how a container of a build pod ended.
+type JobBuilderContainerStatus struct {
+	Name string `json:"name"`
+	// ExitCode is the exit code of the container, once terminated.
+	ExitCode int32 `json:"exitCode,omitempty"`
+	// Reason is why the container terminated or is waiting, e.g. Error,
+	// OOMKilled or ImagePullBackOff.
+	Reason string `json:"reason,omitempty"`
+	// Message is the termination message of the container.
+	Message string `json:"message,omitempty"`
+	// LogTail is the end of the log of the container.
+	LogTail string `json:"logTail,omitempty"`
 }
 
 // JobBuilderStatus defines the observed state of JobBuilder
 type JobBuilderStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the last generation reconciled by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the current state of the resource.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Phase is the current step of the build.
+	Phase JobBuilderPhase `json:"phase,omitempty"`
+	// StartTime is when the build Job was created.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the build reached Succeeded or Failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// JobName is the name of the build Job of the current attempt.
+	JobName string `json:"jobName,omitempty"`
+	// Attempts is the number of build Jobs started.
+	Attempts int32 `json:"attempts,omitempty"`
+	// NextAttemptTime is when the build is retried after a transient failure.
+	NextAttemptTime *metav1.Time `json:"nextAttemptTime,omitempty"`
+	// QueuePosition is the position of a Pending build among the builds
+	// waiting for a free slot, starting at 1.
+	QueuePosition int32 `json:"queuePosition,omitempty"`
+	// JobNamespace is the namespace of the build Job.
+	JobNamespace string `json:"jobNamespace,omitempty"`
+	// Builder is the builder running the build Job.
+	Builder JobBuilderBackend `json:"builder,omitempty"`
+	// Cache summarizes the use of the layer cache by a successful build, it
+	// is only set when the cache is enabled.
+	Cache *JobBuilderCacheStatus `json:"cache,omitempty"`
+	// Containers describe the containers of the last pod of a failed build.
+	Containers []JobBuilderContainerStatus `json:"containers,omitempty"`
+	// Image is the last image successfully built, pinned to its digest.
+	Image string `json:"image,omitempty"`
+	// Digest is the digest of the pushed image.
+	Digest string `json:"digest,omitempty"`
+	// ConfigMapName is the ConfigMap holding the rendered workerd
+	// configuration and Dockerfile of the build.
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// ScriptDigests are the sha256 of the scripts fetched by the last
+	// successful build.
+	ScriptDigests map[string]string `json:"scriptDigests,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Job",type=string,JSONPath=`.status.jobName`
+//+kubebuilder:printcolumn:name="Attempts",type=integer,JSONPath=`.status.attempts`
+//+kubebuilder:printcolumn:name="Queue",type=integer,JSONPath=`.status.queuePosition`,priority=1
+//+kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`
+//+kubebuilder:printcolumn:name="Building",type=string,JSONPath=`.status.conditions[?(@.type=="Building")].status`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // JobBuilder is the Schema for the jobbuilders API
 type JobBuilder struct {
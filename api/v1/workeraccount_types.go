@@ -29,19 +29,49 @@ type PodTemplateWorkerAccount struct {
 
 // WorkerAccountSpec defines the desired state of WorkerAccount
 type WorkerAccountSpec struct {
-	WorkerBundleName      string                   `json:"workerBundleName"`
+	WorkerBundleName string `json:"workerBundleName"`
+	// WorkerReleaseSelector selects the WorkerReleases built into the bundle
+	// of the account. An empty selector selects the releases labelled with
+	// accounts=<account name>.
 	WorkerReleaseSelector metav1.LabelSelector     `json:"workerReleaseSelector"`
 	PodTemplate           PodTemplateWorkerAccount `json:"podTemplate"`
+	// Credentials are the secrets building the releases of the account.
+	//+optional
+	Credentials BuildCredentials `json:"credentials,omitempty"`
 }
 
 // WorkerAccountStatus defines the observed state of WorkerAccount
 type WorkerAccountStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the last generation reconciled by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the current state of the resource.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// WorkerBundleName is the name of the WorkerBundle of the account.
+	WorkerBundleName string `json:"workerBundleName,omitempty"`
+	// WorkerReleaseNames are the WorkerReleases selected by the account, they
+	// are built together into the image of the bundle.
+	WorkerReleaseNames []string `json:"workerReleaseNames,omitempty"`
+	// ReleaseGenerations are the generations of the selected WorkerReleases
+	// the current build of the account was made from.
+	ReleaseGenerations map[string]int64 `json:"releaseGenerations,omitempty"`
+	// JobBuilderName is the name of the JobBuilder building the releases of
+	// the account.
+	JobBuilderName string `json:"jobBuilderName,omitempty"`
+	// Image is the image built for the releases of the account.
+	Image string `json:"image,omitempty"`
+	// Revision is the revision of the account served by the WorkerBundle.
+	Revision int64 `json:"revision,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Bundle",type=string,JSONPath=`.status.workerBundleName`
+//+kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.revision`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // WorkerAccount is the Schema for the workeraccounts API
 type WorkerAccount struct {
@@ -26,8 +26,16 @@ import (
 type Worker struct {
 	WorkerName   string `json:"workerName"`
 	WorkerNumber int32  `json:"workerNumber"`
-	EnvPrefix    string `json:"envPrefix"`
-	SecretRef    string `json:"secretRef"`
+	// EnvPrefix prefixes every variable of the secret, it defaults to the
+	// upper-cased worker name followed by an underscore.
+	EnvPrefix string `json:"envPrefix,omitempty"`
+	// SecretRef is the name of a secret exposed to the worker as environment.
+	SecretRef string `json:"secretRef,omitempty"`
+}
+
+type WorkerUrl struct {
+	WorkerName string `json:"workerName"`
+	Url        string `json:"url"`
 }
 
 type WorkerBundlePodTemplate struct {
@@ -35,21 +43,112 @@ type WorkerBundlePodTemplate struct {
 	ImagePullSecret string `json:"imagePullSecret"`
 }
 
+// WorkerBundleServiceType is the kind of Service exposing the workers.
+// +kubebuilder:validation:Enum=Headless;ClusterIP
+type WorkerBundleServiceType string
+
+const (
+	// ServiceHeadless exposes the pods directly, without a cluster IP.
+	ServiceHeadless WorkerBundleServiceType = "Headless"
+	// ServiceClusterIP load balances the pods behind a cluster IP.
+	ServiceClusterIP WorkerBundleServiceType = "ClusterIP"
+)
+
+// WorkerBundleMode is how the scripts of a bundle reach its pods.
+// +kubebuilder:validation:Enum=Build;Mount
+type WorkerBundleMode string
+
+const (
+	// ModeBuild serves an image built with the scripts by a JobBuilder.
+	ModeBuild WorkerBundleMode = "Build"
+	// ModeMount serves a stock workerd image, the pods fetch the scripts when
+	// they start.
+	ModeMount WorkerBundleMode = "Mount"
+)
+
+// IngressRouting is how requests are routed to the workers of a bundle.
+// +kubebuilder:validation:Enum=Path;Host
+type IngressRouting string
+
+const (
+	// RoutingPath serves every worker on one host under /<workerName>.
+	RoutingPath IngressRouting = "Path"
+	// RoutingHost serves every worker on its own host.
+	RoutingHost IngressRouting = "Host"
+)
+
+type WorkerBundleIngress struct {
+	// Routing selects path based or host based routing.
+	//+kubebuilder:default=Path
+	//+optional
+	Routing IngressRouting `json:"routing,omitempty"`
+	// Host serves the workers with path based routing, it defaults to the
+	// ingress host of the operator configuration.
+	//+optional
+	Host string `json:"host,omitempty"`
+	// HostTemplate is the host of each worker with host based routing, where
+	// {worker} and {account} are replaced, e.g. "{worker}.{account}.example.com".
+	// It defaults to "{worker}." followed by the host.
+	//+optional
+	HostTemplate string `json:"hostTemplate,omitempty"`
+	// ClassName is the ingressClassName of the Ingress.
+	//+optional
+	ClassName *string `json:"className,omitempty"`
+	// TLSSecretName enables TLS for every host with the certificate of this
+	// secret, which cert-manager can issue when configured through Annotations.
+	//+optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// Annotations are added to the Ingress.
+	//+optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
 // WorkerBundleSpec defines the desired state of WorkerBundle
 type WorkerBundleSpec struct {
 	DeploymentName string                  `json:"deploymentName"`
 	Workers        []Worker                `json:"workers,omitempty"`
 	PodTemplate    WorkerBundlePodTemplate `json:"podTemplate"`
+	// ServiceType selects a headless or a ClusterIP Service.
+	//+kubebuilder:default=Headless
+	//+optional
+	ServiceType WorkerBundleServiceType `json:"serviceType,omitempty"`
+	// Ingress configures how the workers are exposed.
+	//+optional
+	Ingress WorkerBundleIngress `json:"ingress,omitempty"`
+	// Mode selects whether the scripts are built into the image of the pods,
+	// or fetched by the pods of a stock workerd image without any build.
+	//+kubebuilder:default=Build
+	//+optional
+	Mode WorkerBundleMode `json:"mode,omitempty"`
+	// Scripts are fetched by the pods in Mount mode, they are set by the
+	// WorkerReleases of the account.
+	//+optional
+	Scripts []JobBuilderScript `json:"scripts,omitempty"`
 }
 
 // WorkerBundleStatus defines the observed state of WorkerBundle
 type WorkerBundleStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the last generation reconciled by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the current state of the resource.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Image is the image currently deployed.
+	Image string `json:"image,omitempty"`
+	// AvailableReplicas is the number of available pods of the Deployment.
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+	// Urls are the ingress urls of each worker.
+	Urls []WorkerUrl `json:"urls,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`
+//+kubebuilder:printcolumn:name="Available",type=integer,JSONPath=`.status.availableReplicas`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // WorkerBundle is the Schema for the workerbundles API
 type WorkerBundle struct {
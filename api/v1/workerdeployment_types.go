@@ -21,25 +21,71 @@ import (
 )
 
 type WorkerDeploymentTemplate struct {
-	ScriptName        string   `json:"scriptName"`
-	SecretRef         string   `json:"secretRef"`
-	CompatibilityDate string   `json:"compatibilityDate"`
-	ScriptsUrls       []string `json:"scriptsUrls"`
+	// ScriptName is the name of the script. It names the directory of the script
+	// and its container port, so it is a DNS label of at most 15 characters.
+	//+kubebuilder:validation:MaxLength=15
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	ScriptName string `json:"scriptName"`
+	SecretRef  string `json:"secretRef,omitempty"`
+	// CompatibilityDate is the workerd compatibility date of the script, as
+	// YYYY-MM-DD.
+	//+optional
+	//+kubebuilder:validation:Pattern=`^\d{4}-\d{2}-\d{2}$`
+	CompatibilityDate string `json:"compatibilityDate,omitempty"`
+	// ScriptUrls locate the script. Only a single url is supported, templates
+	// listing several urls are rejected.
+	//+optional
+	//+kubebuilder:validation:MaxItems=1
+	ScriptUrls []string `json:"scriptUrls,omitempty"`
+	// ScriptsUrls is the former name of scriptUrls, read when scriptUrls is
+	// empty.
+	// Deprecated: use scriptUrls.
+	//+optional
+	ScriptsUrls []string `json:"scriptsUrls,omitempty"`
+	// MainModule is the path of the entry point of the script, relative to
+	// the fetched files. It defaults to worker.js.
+	//+optional
+	MainModule string `json:"mainModule,omitempty"`
+	// Sha256 is the expected digest of the script.
+	//+optional
+	//+kubebuilder:validation:Pattern=`^[a-f0-9]{64}$`
+	Sha256 string `json:"sha256,omitempty"`
 }
 
 type WorkerDeploymentSpec struct {
+	// Accounts is the account the script is deployed to.
+	Accounts            string                   `json:"accounts"`
 	Template            WorkerDeploymentTemplate `json:"template"`
 	ReleaseHistoryLimit int32                    `json:"releaseHistoryLimit"`
 }
 
 // WorkerDeploymentStatus defines the observed state of WorkerDeployment
 type WorkerDeploymentStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the last generation reconciled by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the current state of the resource.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Revision is the revision of the release serving the script.
+	Revision          int64  `json:"revision,omitempty"`
+	WorkerVersionName string `json:"workerVersionName,omitempty"`
+	WorkerReleaseName string `json:"workerReleaseName,omitempty"`
+	WorkerBundleName  string `json:"workerBundleName,omitempty"`
+	// Image is the image serving the script once the rollout is complete.
+	Image string `json:"image,omitempty"`
+	// Url is the ingress url of the script.
+	Url string `json:"url,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Accounts",type=string,JSONPath=`.spec.accounts`
+//+kubebuilder:printcolumn:name="Script",type=string,JSONPath=`.spec.template.scriptName`
+//+kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.revision`
+//+kubebuilder:printcolumn:name="Progressing",type=string,JSONPath=`.status.conditions[?(@.type=="Progressing")].status`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // WorkerDeployment is the Schema for the workerdeployments API
 type WorkerDeployment struct {
@@ -27,16 +27,53 @@ import (
 type WorkerReleaseSpec struct {
 	WorkerVersions map[string]string `json:"workerVersions"`
 	Accounts       string            `json:"accounts"`
+	// SecretRefs maps script names to the secret exposed to them.
+	//+optional
+	SecretRefs map[string]string `json:"secretRefs,omitempty"`
+	// Checksums maps script names to the expected sha256 of the script.
+	//+optional
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// CompatibilityDates maps script names to their workerd compatibility date.
+	//+optional
+	CompatibilityDates map[string]string `json:"compatibilityDates,omitempty"`
+	// MainModules maps script names to the path of their entry point.
+	//+optional
+	MainModules map[string]string `json:"mainModules,omitempty"`
+	// RollbackTo points the WorkerBundle back at the image of a previous
+	// revision of the account without rebuilding it. Builds of the account
+	// are paused until it is cleared, the releases of an account cannot roll
+	// back to different revisions.
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	RollbackTo int64 `json:"rollbackTo,omitempty"`
 }
 
 // WorkerReleaseStatus defines the observed state of WorkerRelease
 type WorkerReleaseStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the last generation reconciled by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the current state of the resource.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// JobBuilderName is the name of the JobBuilder building the releases of
+	// the account.
+	JobBuilderName string `json:"jobBuilderName,omitempty"`
+	// Image is the image built for the release.
+	Image string `json:"image,omitempty"`
+	// Revision is the revision of the account served by the WorkerBundle.
+	Revision int64 `json:"revision,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Accounts",type=string,JSONPath=`.spec.accounts`
+//+kubebuilder:printcolumn:name="JobBuilder",type=string,JSONPath=`.status.jobBuilderName`
+//+kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`
+//+kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.revision`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // WorkerRelease is the Schema for the workerreleases API
 type WorkerRelease struct {
@@ -26,18 +26,50 @@ import (
 // WorkerVersionSpec defines the desired state of WorkerVersion
 type WorkerVersionSpec struct {
 	Accounts string `json:"accounts"`
-	Scripts  string `json:"scripts"`
-	Url      string `json:"url"`
+	// Scripts is the name of the script. It names the directory of the script
+	// and its container port, so it is a DNS label of at most 15 characters.
+	//+kubebuilder:validation:MaxLength=15
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Scripts string `json:"scripts"`
+	Url     string `json:"url"`
+	// SecretRef is the secret exposed to the script at runtime.
+	//+optional
+	SecretRef string `json:"secretRef,omitempty"`
+	// CompatibilityDate is the workerd compatibility date of the script, as
+	// YYYY-MM-DD.
+	//+optional
+	//+kubebuilder:validation:Pattern=`^\d{4}-\d{2}-\d{2}$`
+	CompatibilityDate string `json:"compatibilityDate,omitempty"`
+	// MainModule is the path of the entry point of the script, relative to
+	// the fetched files. It defaults to worker.js.
+	//+optional
+	MainModule string `json:"mainModule,omitempty"`
+	// Sha256 is the expected digest of the script, checked before it is built.
+	//+optional
+	//+kubebuilder:validation:Pattern=`^[a-f0-9]{64}$`
+	Sha256 string `json:"sha256,omitempty"`
 }
 
 // WorkerVersionStatus defines the observed state of WorkerVersion
 type WorkerVersionStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the last generation reconciled by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the current state of the resource.
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// WorkerReleaseName is the name of the WorkerRelease the script was added to.
+	WorkerReleaseName string `json:"workerReleaseName,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Accounts",type=string,JSONPath=`.spec.accounts`
+//+kubebuilder:printcolumn:name="Script",type=string,JSONPath=`.spec.scripts`
+//+kubebuilder:printcolumn:name="Release",type=string,JSONPath=`.status.workerReleaseName`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // WorkerVersion is the Schema for the workerversions API
 type WorkerVersion struct {
@@ -22,16 +22,32 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildCredentials) DeepCopyInto(out *BuildCredentials) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildCredentials.
+func (in *BuildCredentials) DeepCopy() *BuildCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobBuilder) DeepCopyInto(out *JobBuilder) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobBuilder.
@@ -52,6 +68,51 @@ func (in *JobBuilder) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobBuilderBinding) DeepCopyInto(out *JobBuilderBinding) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobBuilderBinding.
+func (in *JobBuilderBinding) DeepCopy() *JobBuilderBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(JobBuilderBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobBuilderCacheStatus) DeepCopyInto(out *JobBuilderCacheStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobBuilderCacheStatus.
+func (in *JobBuilderCacheStatus) DeepCopy() *JobBuilderCacheStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JobBuilderCacheStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobBuilderContainerStatus) DeepCopyInto(out *JobBuilderContainerStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobBuilderContainerStatus.
+func (in *JobBuilderContainerStatus) DeepCopy() *JobBuilderContainerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JobBuilderContainerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobBuilderList) DeepCopyInto(out *JobBuilderList) {
 	*out = *in
@@ -85,18 +146,58 @@ func (in *JobBuilderList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JobBuilderSpec) DeepCopyInto(out *JobBuilderSpec) {
+func (in *JobBuilderScript) DeepCopyInto(out *JobBuilderScript) {
 	*out = *in
-	if in.ScriptUrls != nil {
-		in, out := &in.ScriptUrls, &out.ScriptUrls
-		*out = make([]string, len(*in))
+	if in.Bindings != nil {
+		in, out := &in.Bindings, &out.Bindings
+		*out = make([]JobBuilderBinding, len(*in))
 		copy(*out, *in)
 	}
-	if in.ScriptNames != nil {
-		in, out := &in.ScriptNames, &out.ScriptNames
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobBuilderScript.
+func (in *JobBuilderScript) DeepCopy() *JobBuilderScript {
+	if in == nil {
+		return nil
 	}
+	out := new(JobBuilderScript)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobBuilderSpec) DeepCopyInto(out *JobBuilderSpec) {
+	*out = *in
+	if in.Scripts != nil {
+		in, out := &in.Scripts, &out.Scripts
+		*out = make([]JobBuilderScript, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecretRefs != nil {
+		in, out := &in.SecretRefs, &out.SecretRefs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BuildTimeout != nil {
+		in, out := &in.BuildTimeout, &out.BuildTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MaxAttempts != nil {
+		in, out := &in.MaxAttempts, &out.MaxAttempts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	out.Credentials = in.Credentials
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobBuilderSpec.
@@ -112,6 +213,42 @@ func (in *JobBuilderSpec) DeepCopy() *JobBuilderSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobBuilderStatus) DeepCopyInto(out *JobBuilderStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextAttemptTime != nil {
+		in, out := &in.NextAttemptTime, &out.NextAttemptTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(JobBuilderCacheStatus)
+		**out = **in
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]JobBuilderContainerStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.ScriptDigests != nil {
+		in, out := &in.ScriptDigests, &out.ScriptDigests
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobBuilderStatus.
@@ -160,7 +297,7 @@ func (in *WorkerAccount) DeepCopyInto(out *WorkerAccount) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerAccount.
@@ -218,6 +355,7 @@ func (in *WorkerAccountSpec) DeepCopyInto(out *WorkerAccountSpec) {
 	*out = *in
 	in.WorkerReleaseSelector.DeepCopyInto(&out.WorkerReleaseSelector)
 	out.PodTemplate = in.PodTemplate
+	out.Credentials = in.Credentials
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerAccountSpec.
@@ -233,6 +371,25 @@ func (in *WorkerAccountSpec) DeepCopy() *WorkerAccountSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerAccountStatus) DeepCopyInto(out *WorkerAccountStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.WorkerReleaseNames != nil {
+		in, out := &in.WorkerReleaseNames, &out.WorkerReleaseNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReleaseGenerations != nil {
+		in, out := &in.ReleaseGenerations, &out.ReleaseGenerations
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerAccountStatus.
@@ -251,7 +408,7 @@ func (in *WorkerBundle) DeepCopyInto(out *WorkerBundle) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerBundle.
@@ -272,6 +429,33 @@ func (in *WorkerBundle) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerBundleIngress) DeepCopyInto(out *WorkerBundleIngress) {
+	*out = *in
+	if in.ClassName != nil {
+		in, out := &in.ClassName, &out.ClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerBundleIngress.
+func (in *WorkerBundleIngress) DeepCopy() *WorkerBundleIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerBundleIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerBundleList) DeepCopyInto(out *WorkerBundleList) {
 	*out = *in
@@ -328,6 +512,14 @@ func (in *WorkerBundleSpec) DeepCopyInto(out *WorkerBundleSpec) {
 		copy(*out, *in)
 	}
 	out.PodTemplate = in.PodTemplate
+	in.Ingress.DeepCopyInto(&out.Ingress)
+	if in.Scripts != nil {
+		in, out := &in.Scripts, &out.Scripts
+		*out = make([]JobBuilderScript, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerBundleSpec.
@@ -343,6 +535,18 @@ func (in *WorkerBundleSpec) DeepCopy() *WorkerBundleSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerBundleStatus) DeepCopyInto(out *WorkerBundleStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Urls != nil {
+		in, out := &in.Urls, &out.Urls
+		*out = make([]WorkerUrl, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerBundleStatus.
@@ -361,7 +565,7 @@ func (in *WorkerDeployment) DeepCopyInto(out *WorkerDeployment) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerDeployment.
@@ -433,6 +637,13 @@ func (in *WorkerDeploymentSpec) DeepCopy() *WorkerDeploymentSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerDeploymentStatus) DeepCopyInto(out *WorkerDeploymentStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerDeploymentStatus.
@@ -448,6 +659,11 @@ func (in *WorkerDeploymentStatus) DeepCopy() *WorkerDeploymentStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerDeploymentTemplate) DeepCopyInto(out *WorkerDeploymentTemplate) {
 	*out = *in
+	if in.ScriptUrls != nil {
+		in, out := &in.ScriptUrls, &out.ScriptUrls
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.ScriptsUrls != nil {
 		in, out := &in.ScriptsUrls, &out.ScriptsUrls
 		*out = make([]string, len(*in))
@@ -471,7 +687,7 @@ func (in *WorkerRelease) DeepCopyInto(out *WorkerRelease) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerRelease.
@@ -534,6 +750,34 @@ func (in *WorkerReleaseSpec) DeepCopyInto(out *WorkerReleaseSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.SecretRefs != nil {
+		in, out := &in.SecretRefs, &out.SecretRefs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Checksums != nil {
+		in, out := &in.Checksums, &out.Checksums
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.CompatibilityDates != nil {
+		in, out := &in.CompatibilityDates, &out.CompatibilityDates
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MainModules != nil {
+		in, out := &in.MainModules, &out.MainModules
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerReleaseSpec.
@@ -549,6 +793,13 @@ func (in *WorkerReleaseSpec) DeepCopy() *WorkerReleaseSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerReleaseStatus) DeepCopyInto(out *WorkerReleaseStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerReleaseStatus.
@@ -561,13 +812,28 @@ func (in *WorkerReleaseStatus) DeepCopy() *WorkerReleaseStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerUrl) DeepCopyInto(out *WorkerUrl) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerUrl.
+func (in *WorkerUrl) DeepCopy() *WorkerUrl {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerUrl)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerVersion) DeepCopyInto(out *WorkerVersion) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerVersion.
@@ -638,6 +904,13 @@ func (in *WorkerVersionSpec) DeepCopy() *WorkerVersionSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerVersionStatus) DeepCopyInto(out *WorkerVersionStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerVersionStatus.
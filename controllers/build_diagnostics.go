@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+const (
+	// buildLogTailLines is the number of lines kept from the log of each
+	// container of a failed build.
+	buildLogTailLines = int64(20)
+	// maxLogTailBytes bounds the log tail of a container kept in the status.
+	maxLogTailBytes = 2048
+	// cacheLogTailLines is the number of lines of the log of a kaniko build
+	// read to count its cache hits, kaniko logs one line per cached command.
+	cacheLogTailLines = int64(1000)
+	// maxCacheLogBytes bounds the log of a kaniko build read for its cache
+	// hits.
+	maxCacheLogBytes = int64(1 << 20)
+	// defaultMaxAttempts is used for the JobBuilders created before their
+	// maxAttempts was defaulted.
+	defaultMaxAttempts = int32(3)
+	// firstRetryDelay is the delay before the second attempt of a build, it
+	// doubles with each attempt up to maxRetryDelay.
+	firstRetryDelay = 10 * time.Second
+	maxRetryDelay   = 5 * time.Minute
+)
+
+// transientFailures are the log and termination messages of failures worth
+// retrying, registry and S3 errors, throttling and network timeouts.
+var transientFailures = []string{
+	"500 Internal Server Error",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+	"429 Too Many Requests",
+	"TOOMANYREQUESTS",
+	"SlowDown",
+	"RequestLimitExceeded",
+	"Throttling",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"connection reset by peer",
+	"connection refused",
+	"Could not resolve host",
+}
+
+// isEvicted reports whether a build pod was evicted, the kubelet records it on
+// the pod rather than on its containers.
+func isEvicted(pod *corev1.Pod) bool {
+	return pod != nil && pod.Status.Phase == corev1.PodFailed && pod.Status.Reason == "Evicted"
+}
+
+// isTransientFailure reports whether a build pod was evicted, or whether its
+// failed containers ran into an error worth retrying.
+func isTransientFailure(pod *corev1.Pod, statuses []apiv1.JobBuilderContainerStatus) bool {
+	if isEvicted(pod) {
+		return true
+	}
+	for _, status := range statuses {
+		if status.ExitCode == 0 {
+			continue
+		}
+		for _, failure := range transientFailures {
+			if strings.Contains(status.LogTail, failure) || strings.Contains(status.Message, failure) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getMaxAttempts(instance *apiv1.JobBuilder) int32 {
+	if instance.Spec.MaxAttempts != nil {
+		return *instance.Spec.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+// getRetryDelay returns the delay before the attempt following attempt.
+func getRetryDelay(attempt int32) time.Duration {
+	delay := firstRetryDelay
+	for i := int32(1); i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// getLastPod returns the most recent pod of a build, the Job retries failed
+// pods.
+func getLastPod(pods []corev1.Pod) *corev1.Pod {
+	var last *corev1.Pod
+	for i := range pods {
+		if last == nil || last.CreationTimestamp.Before(&pods[i].CreationTimestamp) {
+			last = &pods[i]
+		}
+	}
+	return last
+}
+
+// getContainerStatuses describes the containers of a build pod, in the order
+// they run, with the tail of their logs. Containers that never started are
+// left out.
+func (r *JobBuilderReconciler) getContainerStatuses(ctx context.Context, pod *corev1.Pod) []apiv1.JobBuilderContainerStatus {
+	var statuses []apiv1.JobBuilderContainerStatus
+	for _, containerStatus := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		status := apiv1.JobBuilderContainerStatus{Name: containerStatus.Name}
+		switch {
+		case containerStatus.State.Terminated != nil:
+			status.ExitCode = containerStatus.State.Terminated.ExitCode
+			status.Reason = containerStatus.State.Terminated.Reason
+			status.Message = strings.TrimSpace(containerStatus.State.Terminated.Message)
+		case containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason != "PodInitializing" && containerStatus.State.Waiting.Reason != "ContainerCreating":
+			status.Reason = containerStatus.State.Waiting.Reason
+			status.Message = containerStatus.State.Waiting.Message
+			statuses = append(statuses, status)
+			continue
+		default:
+			continue
+		}
+		tailLines := buildLogTailLines
+		logs, err := r.getContainerLogs(ctx, pod, containerStatus.Name, &tailLines, nil)
+		if err != nil {
+			log.Log.WithValues("Pod", client.ObjectKeyFromObject(pod)).Info("unable to read the logs of a build container", "container", containerStatus.Name, "error", err.Error())
+		}
+		if len(logs) > maxLogTailBytes {
+			logs = logs[len(logs)-maxLogTailBytes:]
+		}
+		status.LogTail = strings.TrimSpace(logs)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// describeFailure explains a failed build from the first container that
+// failed, with its last log line.
+func describeFailure(statuses []apiv1.JobBuilderContainerStatus) string {
+	for _, status := range statuses {
+		if status.ExitCode == 0 && (status.Reason == "" || status.Reason == "Completed") {
+			continue
+		}
+		message := status.Name
+		if status.ExitCode != 0 {
+			message += fmt.Sprintf(" exited with code %d", status.ExitCode)
+		}
+		if status.Reason != "" {
+			message += " (" + status.Reason + ")"
+		}
+		detail := status.Message
+		if detail == "" && status.LogTail != "" {
+			lines := strings.Split(status.LogTail, "\n")
+			detail = lines[len(lines)-1]
+		}
+		if detail != "" {
+			message += ": " + detail
+		}
+		return message
+	}
+	return "the build job failed"
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+func TestIsTransientFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		statuses []apiv1.JobBuilderContainerStatus
+		want     bool
+	}{
+		{
+			name: "evicted pod",
+			pod:  &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: ephemeral-storage."}},
+			want: true,
+		},
+		{
+			name:     "registry error",
+			statuses: []apiv1.JobBuilderContainerStatus{{Name: "kaniko", ExitCode: 1, LogTail: "error pushing image: 503 Service Unavailable"}},
+			want:     true,
+		},
+		{
+			name:     "transient message of a successful container",
+			statuses: []apiv1.JobBuilderContainerStatus{{Name: "fetch-s3-8080", LogTail: "retrying after i/o timeout"}, {Name: "kaniko", ExitCode: 1, LogTail: "syntax error"}},
+			want:     false,
+		},
+		{
+			name:     "script error",
+			pod:      &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed}},
+			statuses: []apiv1.JobBuilderContainerStatus{{Name: "kaniko", ExitCode: 1, LogTail: "syntax error"}},
+			want:     false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTransientFailure(test.pod, test.statuses); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
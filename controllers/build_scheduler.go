@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+// queuedBuildRequeue is how often a queued build checks for a free slot, on
+// top of the builds finishing.
+const queuedBuildRequeue = time.Minute
+
+// getBuildAccount groups the builds limited together, by account, or by
+// bundle for the JobBuilders created without one.
+func getBuildAccount(instance *apiv1.JobBuilder) string {
+	if account := getAccount(instance); account != "" {
+		return account
+	}
+	return instance.GetNamespace() + "/" + instance.Spec.WorkerBundleName
+}
+
+func isBuildRunning(instance *apiv1.JobBuilder) bool {
+	return instance.Status.Phase == apiv1.JobBuilderBuilding
+}
+
+// isBuildQueued reports whether a build waits for a slot, builds waiting for
+// the backoff of a retry are not queued yet.
+func isBuildQueued(instance *apiv1.JobBuilder) bool {
+	if !instance.DeletionTimestamp.IsZero() {
+		return false
+	}
+	if instance.Status.Phase != "" && instance.Status.Phase != apiv1.JobBuilderPending {
+		return false
+	}
+	return instance.Status.NextAttemptTime == nil || !instance.Status.NextAttemptTime.After(time.Now())
+}
+
+// sortBuildQueue orders queued builds by priority, then first in first out.
+func sortBuildQueue(queue []*apiv1.JobBuilder) {
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].Spec.Priority != queue[j].Spec.Priority {
+			return queue[i].Spec.Priority > queue[j].Spec.Priority
+		}
+		if !queue[i].CreationTimestamp.Equal(&queue[j].CreationTimestamp) {
+			return queue[i].CreationTimestamp.Before(&queue[j].CreationTimestamp)
+		}
+		return client.ObjectKeyFromObject(queue[i]).String() < client.ObjectKeyFromObject(queue[j]).String()
+	})
+}
+
+// buildSchedule is the decision of the scheduler for a queued build.
+type buildSchedule struct {
+	// Position is the position of the build in the queue, 0 when it may
+	// start.
+	Position int32
+	// SupersededBy names a newer queued build of the same account and
+	// bundle, the build is dropped in its favor.
+	SupersededBy string
+}
+
+// scheduleBuild decides whether a queued build may start. The queue is walked
+// in order, each build taking a slot when neither the global limit nor the
+// limit of its account is reached.
+func (r *JobBuilderReconciler) scheduleBuild(ctx context.Context, instance *apiv1.JobBuilder) (buildSchedule, error) {
+	jobBuilders := &apiv1.JobBuilderList{}
+	err := r.List(ctx, jobBuilders)
+	if err != nil {
+		return buildSchedule{}, err
+	}
+
+	running := 0
+	runningByAccount := map[string]int{}
+	var queue []*apiv1.JobBuilder
+	for i := range jobBuilders.Items {
+		jobBuilder := &jobBuilders.Items[i]
+		if jobBuilder.UID == instance.UID {
+			jobBuilder = instance
+		}
+		switch {
+		case isBuildRunning(jobBuilder):
+			running++
+			runningByAccount[getBuildAccount(jobBuilder)]++
+		case isBuildQueued(jobBuilder):
+			queue = append(queue, jobBuilder)
+		}
+	}
+	sortBuildQueue(queue)
+
+	// pending builds of an account and bundle coalesce into the latest one
+	for _, queued := range queue {
+		if queued.UID != instance.UID && getBuildAccount(queued) == getBuildAccount(instance) &&
+			queued.Spec.WorkerBundleName == instance.Spec.WorkerBundleName &&
+			instance.CreationTimestamp.Before(&queued.CreationTimestamp) {
+			return buildSchedule{SupersededBy: queued.Name}, nil
+		}
+	}
+
+	maxConcurrent := r.Config.Resolve("", "").Builds.MaxConcurrent
+	position := int32(0)
+	for _, queued := range queue {
+		account := getBuildAccount(queued)
+		maxPerAccount := r.resolveSettings(queued).Builds.MaxConcurrentPerAccount
+		if (maxConcurrent <= 0 || running < maxConcurrent) && (maxPerAccount <= 0 || runningByAccount[account] < maxPerAccount) {
+			if queued.UID == instance.UID {
+				return buildSchedule{}, nil
+			}
+			running++
+			runningByAccount[account]++
+			continue
+		}
+		position++
+		if queued.UID == instance.UID {
+			return buildSchedule{Position: position}, nil
+		}
+	}
+	return buildSchedule{}, nil
+}
+
+// findQueuedJobBuilders enqueues the queued builds once a build releases its
+// slot.
+func (r *JobBuilderReconciler) findQueuedJobBuilders(obj client.Object) []reconcile.Request {
+	jobBuilder := obj.(*apiv1.JobBuilder)
+	if jobBuilder.DeletionTimestamp.IsZero() && (isBuildRunning(jobBuilder) || isBuildQueued(jobBuilder)) {
+		return nil
+	}
+	jobBuilders := &apiv1.JobBuilderList{}
+	err := r.List(context.Background(), jobBuilders)
+	if err != nil {
+		log.Log.Error(err, "unable to list the queued builds")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range jobBuilders.Items {
+		if isBuildQueued(&jobBuilders.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&jobBuilders.Items[i])})
+		}
+	}
+	return requests
+}
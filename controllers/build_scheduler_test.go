@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+// newTestJobBuilder returns a JobBuilder of account building bundle, created
+// age ago.
+func newTestJobBuilder(name string, account string, bundle string, age time.Duration, phase apiv1.JobBuilderPhase) *apiv1.JobBuilder {
+	return &apiv1.JobBuilder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name),
+			Labels:            map[string]string{accountLabel: account},
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Spec:   apiv1.JobBuilderSpec{WorkerBundleName: bundle},
+		Status: apiv1.JobBuilderStatus{Phase: phase},
+	}
+}
+
+func TestScheduleBuild(t *testing.T) {
+	retrying := newTestJobBuilder("retrying", "c", "bundle-c", time.Hour, apiv1.JobBuilderPending)
+	nextAttempt := metav1.NewTime(time.Now().Add(time.Minute))
+	retrying.Status.NextAttemptTime = &nextAttempt
+	urgent := newTestJobBuilder("urgent", "d", "bundle-d", time.Second, apiv1.JobBuilderPending)
+	urgent.Spec.Priority = 10
+
+	tests := []struct {
+		name          string
+		maxConcurrent int
+		maxPerAccount int
+		jobBuilders   []*apiv1.JobBuilder
+		instance      string
+		want          buildSchedule
+	}{
+		{
+			name:          "free slot",
+			maxConcurrent: 2,
+			maxPerAccount: 1,
+			jobBuilders: []*apiv1.JobBuilder{
+				newTestJobBuilder("running", "a", "bundle-a", time.Hour, apiv1.JobBuilderBuilding),
+				newTestJobBuilder("queued", "b", "bundle-b", time.Minute, apiv1.JobBuilderPending),
+			},
+			instance: "queued",
+			want:     buildSchedule{},
+		},
+		{
+			name:          "global limit",
+			maxConcurrent: 1,
+			maxPerAccount: 1,
+			jobBuilders: []*apiv1.JobBuilder{
+				newTestJobBuilder("running", "a", "bundle-a", time.Hour, apiv1.JobBuilderBuilding),
+				newTestJobBuilder("first", "b", "bundle-b", 2*time.Minute, apiv1.JobBuilderPending),
+				newTestJobBuilder("second", "c", "bundle-c", time.Minute, apiv1.JobBuilderPending),
+			},
+			instance: "second",
+			want:     buildSchedule{Position: 2},
+		},
+		{
+			name:          "account limit",
+			maxConcurrent: 5,
+			maxPerAccount: 1,
+			jobBuilders: []*apiv1.JobBuilder{
+				newTestJobBuilder("running", "a", "bundle-a", time.Hour, apiv1.JobBuilderBuilding),
+				newTestJobBuilder("same-account", "a", "other-bundle", 2*time.Minute, apiv1.JobBuilderPending),
+				newTestJobBuilder("other-account", "b", "bundle-b", time.Minute, apiv1.JobBuilderPending),
+			},
+			instance: "same-account",
+			want:     buildSchedule{Position: 1},
+		},
+		{
+			name:          "a blocked account does not hold the queue",
+			maxConcurrent: 5,
+			maxPerAccount: 1,
+			jobBuilders: []*apiv1.JobBuilder{
+				newTestJobBuilder("running", "a", "bundle-a", time.Hour, apiv1.JobBuilderBuilding),
+				newTestJobBuilder("same-account", "a", "other-bundle", 2*time.Minute, apiv1.JobBuilderPending),
+				newTestJobBuilder("other-account", "b", "bundle-b", time.Minute, apiv1.JobBuilderPending),
+			},
+			instance: "other-account",
+			want:     buildSchedule{},
+		},
+		{
+			name:          "priority first",
+			maxConcurrent: 1,
+			maxPerAccount: 1,
+			jobBuilders: []*apiv1.JobBuilder{
+				newTestJobBuilder("running", "a", "bundle-a", time.Hour, apiv1.JobBuilderBuilding),
+				newTestJobBuilder("old", "b", "bundle-b", time.Hour, apiv1.JobBuilderPending),
+				urgent,
+			},
+			instance: "old",
+			want:     buildSchedule{Position: 2},
+		},
+		{
+			name:          "builds waiting for a retry are not queued",
+			maxConcurrent: 1,
+			maxPerAccount: 1,
+			jobBuilders: []*apiv1.JobBuilder{
+				retrying,
+				newTestJobBuilder("queued", "b", "bundle-b", time.Minute, apiv1.JobBuilderPending),
+			},
+			instance: "queued",
+			want:     buildSchedule{},
+		},
+		{
+			name:          "unlimited",
+			maxConcurrent: 0,
+			maxPerAccount: 0,
+			jobBuilders: []*apiv1.JobBuilder{
+				newTestJobBuilder("running", "a", "bundle-a", time.Hour, apiv1.JobBuilderBuilding),
+				newTestJobBuilder("queued", "a", "other-bundle", time.Minute, apiv1.JobBuilderPending),
+			},
+			instance: "queued",
+			want:     buildSchedule{},
+		},
+		{
+			name:          "older builds of a bundle are superseded",
+			maxConcurrent: 5,
+			maxPerAccount: 1,
+			jobBuilders: []*apiv1.JobBuilder{
+				newTestJobBuilder("older", "a", "bundle-a", time.Hour, apiv1.JobBuilderPending),
+				newTestJobBuilder("newer", "a", "bundle-a", time.Minute, apiv1.JobBuilderPending),
+			},
+			instance: "older",
+			want:     buildSchedule{SupersededBy: "newer"},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := make([]client.Object, len(test.jobBuilders))
+			var instance *apiv1.JobBuilder
+			for i, jobBuilder := range test.jobBuilders {
+				objects[i] = jobBuilder.DeepCopy()
+				if jobBuilder.Name == test.instance {
+					instance = jobBuilder.DeepCopy()
+				}
+			}
+			config := DefaultOperatorConfig()
+			config.Builds = BuildSettings{MaxConcurrent: test.maxConcurrent, MaxConcurrentPerAccount: test.maxPerAccount}
+			r := &JobBuilderReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+				Config: config,
+			}
+
+			got, err := r.scheduleBuild(context.Background(), instance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
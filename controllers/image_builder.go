@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+// imageBuilder builds and pushes the image of a JobBuilder.
+type imageBuilder interface {
+	// podTemplate returns the pod of the build Job.
+	podTemplate(instance *apiv1.JobBuilder, settings *OperatorSettings) (v1.PodTemplateSpec, error)
+	// digest reads the digest of the pushed image from a finished build pod,
+	// it is empty when the builder did not report it.
+	digest(pod *v1.Pod) string
+}
+
+// getBuilderBackend returns the builder of a JobBuilder, the one of the
+// operator settings unless the JobBuilder overrides it.
+func getBuilderBackend(instance *apiv1.JobBuilder, settings *OperatorSettings) apiv1.JobBuilderBackend {
+	if instance.Spec.Builder != "" {
+		return instance.Spec.Builder
+	}
+	if settings.Builder != "" {
+		return apiv1.JobBuilderBackend(settings.Builder)
+	}
+	return apiv1.BackendKaniko
+}
+
+func getImageBuilder(backend apiv1.JobBuilderBackend) (imageBuilder, error) {
+	switch backend {
+	// builds started before the builder was recorded ran kaniko
+	case "", apiv1.BackendKaniko:
+		return kanikoBuilder{}, nil
+	case apiv1.BackendBuildKit:
+		return buildKitBuilder{}, nil
+	case apiv1.BackendPrebuilt:
+		return prebuiltBuilder{}, nil
+	}
+	return nil, fmt.Errorf("unsupported builder %q", backend)
+}
+
+// getTerminationMessage returns the termination message of a container of a
+// pod that exited successfully.
+func getTerminationMessage(pod *v1.Pod, container string) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container && status.State.Terminated != nil && status.State.Terminated.ExitCode == 0 {
+			return strings.TrimSpace(status.State.Terminated.Message)
+		}
+	}
+	return ""
+}
+
+func getDigest(message string) string {
+	if strings.HasPrefix(message, "sha256:") {
+		return message
+	}
+	return ""
+}
+
+type kanikoBuilder struct{}
+
+func isCacheEnabled(settings *OperatorSettings) bool {
+	return settings.Cache.Enabled != nil && *settings.Cache.Enabled
+}
+
+func (kanikoBuilder) podTemplate(instance *apiv1.JobBuilder, settings *OperatorSettings) (v1.PodTemplateSpec, error) {
+	kaniko := v1.Container{
+		Name:  "kaniko",
+		Image: settings.Images.Kaniko,
+		Args: []string{
+			"--dockerfile=Dockerfile",
+			"--context=/context",
+			fmt.Sprintf("--destination=%s", instance.Spec.TargetImage),
+			// the digest of the pushed image becomes the termination message
+			"--digest-file=/dev/termination-log",
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "registry-credentials", MountPath: "/kaniko/.docker/", ReadOnly: true},
+			{Name: "context", MountPath: "/context"},
+		},
+	}
+	if !isCacheEnabled(settings) {
+		return generateContextPodTemplate(instance, settings, kaniko)
+	}
+
+	kaniko.Args = append(kaniko.Args, "--cache=true")
+	if settings.Cache.Repository != "" {
+		kaniko.Args = append(kaniko.Args, "--cache-repo="+settings.Cache.Repository)
+	}
+	if settings.Cache.TTL != "" {
+		kaniko.Args = append(kaniko.Args, "--cache-ttl="+settings.Cache.TTL)
+	}
+	if settings.Cache.ClaimName == "" {
+		return generateContextPodTemplate(instance, settings, kaniko)
+	}
+
+	kaniko.Args = append(kaniko.Args, "--cache-dir=/cache")
+	kaniko.VolumeMounts = append(kaniko.VolumeMounts, v1.VolumeMount{Name: "kaniko-cache", MountPath: "/cache", ReadOnly: true})
+	template, err := generateContextPodTemplate(instance, settings, kaniko, v1.Volume{
+		Name: "kaniko-cache",
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: settings.Cache.ClaimName},
+		},
+	})
+	if err != nil {
+		return template, err
+	}
+	// the warmer skips the base images already cached
+	template.Spec.InitContainers = append(template.Spec.InitContainers, v1.Container{
+		Name:  "warm-cache",
+		Image: settings.Images.KanikoWarmer,
+		Args: []string{
+			"--cache-dir=/cache",
+			"--image=" + settings.Images.WorkerdBuilder,
+			"--image=" + settings.Images.WorkerdRunner,
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "registry-credentials", MountPath: "/kaniko/.docker/", ReadOnly: true},
+			{Name: "kaniko-cache", MountPath: "/cache"},
+		},
+	})
+	return template, nil
+}
+
+// getKanikoCacheStatus counts the layers kaniko reused from its cache and the
+// ones it had to build, from the logs of a build.
+func getKanikoCacheStatus(logs string) *apiv1.JobBuilderCacheStatus {
+	return &apiv1.JobBuilderCacheStatus{
+		Hits:   int32(strings.Count(logs, "Using caching version of cmd")),
+		Misses: int32(strings.Count(logs, "No cached layer found for cmd")),
+	}
+}
+
+func (kanikoBuilder) digest(pod *v1.Pod) string {
+	return getDigest(getTerminationMessage(pod, "kaniko"))
+}
+
+// buildKitBuilder runs a daemonless rootless BuildKit, which needs neither a
+// privileged container nor a process sandbox.
+type buildKitBuilder struct{}
+
+func (buildKitBuilder) podTemplate(instance *apiv1.JobBuilder, settings *OperatorSettings) (v1.PodTemplateSpec, error) {
+	user := int64(1000)
+	template, err := generateContextPodTemplate(instance, settings, v1.Container{
+		Name:    "buildkit",
+		Image:   settings.Images.BuildKit,
+		Command: []string{"buildctl-daemonless.sh"},
+		Args: []string{
+			"build",
+			"--frontend=dockerfile.v0",
+			"--local=context=/context",
+			"--local=dockerfile=/context",
+			fmt.Sprintf("--output=type=image,name=%s,push=true", instance.Spec.TargetImage),
+			// the metadata of the pushed image becomes the termination message
+			"--metadata-file=/dev/termination-log",
+		},
+		Env: []v1.EnvVar{
+			{Name: "BUILDKITD_FLAGS", Value: "--oci-worker-no-process-sandbox"},
+		},
+		SecurityContext: &v1.SecurityContext{
+			RunAsUser:      &user,
+			RunAsGroup:     &user,
+			SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeUnconfined},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "registry-credentials", MountPath: "/home/user/.docker", ReadOnly: true},
+			{Name: "context", MountPath: "/context", ReadOnly: true},
+			{Name: "buildkitd", MountPath: "/home/user/.local/share/buildkit"},
+		},
+	}, v1.Volume{Name: "buildkitd", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}})
+	if err != nil {
+		return template, err
+	}
+	template.Annotations = map[string]string{
+		"container.apparmor.security.beta.kubernetes.io/buildkit": "unconfined",
+	}
+	return template, nil
+}
+
+func (buildKitBuilder) digest(pod *v1.Pod) string {
+	metadata := map[string]interface{}{}
+	err := json.Unmarshal([]byte(getTerminationMessage(pod, "buildkit")), &metadata)
+	if err != nil {
+		return ""
+	}
+	digest, _ := metadata["containerimage.digest"].(string)
+	return getDigest(digest)
+}
+
+// prebuiltBuilder serves a target image pushed beforehand, e.g. by a CI, the
+// build Job only resolves its digest and the scripts are not fetched.
+type prebuiltBuilder struct{}
+
+func (prebuiltBuilder) podTemplate(instance *apiv1.JobBuilder, settings *OperatorSettings) (v1.PodTemplateSpec, error) {
+	return v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:            "resolve-digest",
+				Image:           settings.Images.Crane,
+				ImagePullPolicy: "IfNotPresent",
+				Env: []v1.EnvVar{
+					{Name: "DOCKER_CONFIG", Value: "/docker"},
+					{Name: "TARGET_IMAGE", Value: instance.Spec.TargetImage},
+				},
+				VolumeMounts: []v1.VolumeMount{
+					{Name: "registry-credentials", MountPath: "/docker", ReadOnly: true},
+				},
+				Command: []string{"sh", "-c"},
+				Args:    []string{"crane digest \"$TARGET_IMAGE\" > /dev/termination-log"},
+			}},
+			Volumes:       []v1.Volume{generateRegistryCredentialsVolume(settings)},
+			RestartPolicy: "Never",
+		},
+	}, nil
+}
+
+func (prebuiltBuilder) digest(pod *v1.Pod) string {
+	return getDigest(getTerminationMessage(pod, "resolve-digest"))
+}
@@ -4,48 +4,131 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apiv1 "operators/WorkerBundle/api/v1"
+	"strings"
 )
 
+func isHostRouting(instance *apiv1.WorkerBundle) bool {
+	return instance.Spec.Ingress.Routing == apiv1.RoutingHost
+}
+
+func getIngressHost(instance *apiv1.WorkerBundle, settings *OperatorSettings) string {
+	if instance.Spec.Ingress.Host != "" {
+		return instance.Spec.Ingress.Host
+	}
+	return settings.Ingress.Host
+}
+
+func getWorkerHost(instance *apiv1.WorkerBundle, worker apiv1.Worker, settings *OperatorSettings) string {
+	hostTemplate := instance.Spec.Ingress.HostTemplate
+	if hostTemplate == "" {
+		hostTemplate = "{worker}." + getIngressHost(instance, settings)
+	}
+	return strings.NewReplacer("{worker}", worker.WorkerName, "{account}", getAccount(instance)).Replace(hostTemplate)
+}
+
+func createIngressPath(instance *apiv1.WorkerBundle, worker apiv1.Worker, path string) networkingv1.HTTPIngressPath {
+	pathType := networkingv1.PathTypePrefix
+	return networkingv1.HTTPIngressPath{
+		Path:     path,
+		PathType: &pathType,
+		Backend: networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{
+				Name: getServiceName(instance.Spec.DeploymentName),
+				Port: networkingv1.ServiceBackendPort{
+					Number: worker.WorkerNumber,
+				},
+			},
+		},
+	}
+}
+
 func createIngressPaths(instance *apiv1.WorkerBundle) []networkingv1.HTTPIngressPath {
 	paths := make([]networkingv1.HTTPIngressPath, len(instance.Spec.Workers))
-	pathType := networkingv1.PathTypePrefix
 	for i, worker := range instance.Spec.Workers {
-		paths[i] = networkingv1.HTTPIngressPath{
-			Path:     getIngressPathName(worker),
-			PathType: &pathType,
-			Backend: networkingv1.IngressBackend{
-				Service: &networkingv1.IngressServiceBackend{
-					Name: getServiceName(worker.WorkerName),
-					Port: networkingv1.ServiceBackendPort{
-						Number: worker.WorkerNumber,
+		paths[i] = createIngressPath(instance, worker, getIngressPathName(worker))
+	}
+	return paths
+}
+
+// createIngressRules routes a single host by path, or one host per worker.
+func createIngressRules(instance *apiv1.WorkerBundle, settings *OperatorSettings) []networkingv1.IngressRule {
+	if !isHostRouting(instance) {
+		return []networkingv1.IngressRule{
+			{
+				Host: getIngressHost(instance, settings),
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: createIngressPaths(instance),
 					},
 				},
 			},
 		}
 	}
-	return paths
+
+	rules := make([]networkingv1.IngressRule, len(instance.Spec.Workers))
+	for i, worker := range instance.Spec.Workers {
+		rules[i] = networkingv1.IngressRule{
+			Host: getWorkerHost(instance, worker, settings),
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{createIngressPath(instance, worker, "/")},
+				},
+			},
+		}
+	}
+	return rules
+}
+
+func createIngressTLS(instance *apiv1.WorkerBundle, rules []networkingv1.IngressRule) []networkingv1.IngressTLS {
+	if instance.Spec.Ingress.TLSSecretName == "" {
+		return nil
+	}
+	hosts := make([]string, len(rules))
+	for i, rule := range rules {
+		hosts[i] = rule.Host
+	}
+	return []networkingv1.IngressTLS{{Hosts: hosts, SecretName: instance.Spec.Ingress.TLSSecretName}}
+}
+
+func createIngressAnnotations(instance *apiv1.WorkerBundle) map[string]string {
+	annotations := map[string]string{}
+	if !isHostRouting(instance) {
+		annotations["nginx.ingress.kubernetes.io/rewrite-target"] = "/"
+	}
+	for key, value := range instance.Spec.Ingress.Annotations {
+		annotations[key] = value
+	}
+	return annotations
 }
 
-func createIngress(instance *apiv1.WorkerBundle) *networkingv1.Ingress {
+func createIngress(instance *apiv1.WorkerBundle, settings *OperatorSettings) *networkingv1.Ingress {
+	rules := createIngressRules(instance, settings)
 	return &networkingv1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      getIngressName(instance.Spec.DeploymentName),
-			Namespace: instance.Namespace,
-			Annotations: map[string]string{
-				"nginx.ingress.kubernetes.io/rewrite-target": "/",
-			},
+			Name:        getIngressName(instance.Spec.DeploymentName),
+			Namespace:   instance.Namespace,
+			Annotations: createIngressAnnotations(instance),
 		},
 		Spec: networkingv1.IngressSpec{
-			Rules: []networkingv1.IngressRule{
-				{
-					Host: "worker.127.0.0.1.sslip.io",
-					IngressRuleValue: networkingv1.IngressRuleValue{
-						HTTP: &networkingv1.HTTPIngressRuleValue{
-							Paths: createIngressPaths(instance),
-						},
-					},
-				},
-			},
+			IngressClassName: instance.Spec.Ingress.ClassName,
+			TLS:              createIngressTLS(instance, rules),
+			Rules:            rules,
 		},
 	}
 }
+
+func getWorkerUrls(instance *apiv1.WorkerBundle, settings *OperatorSettings) []apiv1.WorkerUrl {
+	scheme := "http://"
+	if instance.Spec.Ingress.TLSSecretName != "" {
+		scheme = "https://"
+	}
+	urls := make([]apiv1.WorkerUrl, len(instance.Spec.Workers))
+	for i, worker := range instance.Spec.Workers {
+		url := scheme + getIngressHost(instance, settings) + getIngressPathName(worker)
+		if isHostRouting(instance) {
+			url = scheme + getWorkerHost(instance, worker, settings) + "/"
+		}
+		urls[i] = apiv1.WorkerUrl{WorkerName: worker.WorkerName, Url: url}
+	}
+	return urls
+}
@@ -9,154 +9,268 @@ import (
 	"strings"
 )
 
-func generateAwsConfig() []v1.EnvVar {
+func generateAwsConfig(settings *OperatorSettings) []v1.EnvVar {
 	return []v1.EnvVar{
 		{Name: "AWS_PROFILE", Value: "default"},
-		{Name: "AWS_ENDPOINT", Value: "https://s3.fr-par.scw.cloud"},
-		{Name: "AWS_BUCKET", Value: "stage-cf-worker"},
+		{Name: "AWS_ENDPOINT", Value: settings.Storage.Endpoint},
+		{Name: "AWS_BUCKET", Value: settings.Storage.Bucket},
+		{Name: "AWS_REGION", Value: settings.Storage.Region},
 	}
 }
 
-func generateCmdPrebuild(scriptUrls []string, finalPath string) string {
-	return fmt.Sprintf(
-		"--s3-bucket-name %s --s3-endpoint %s --s3-region fr-par --destination %s --s3-object-key %s",
-		"$(AWS_BUCKET)",
-		"$(AWS_ENDPOINT)",
-		finalPath,
-		strings.Join(scriptUrls, ","),
-	)
-}
+// verifyScriptsCommand computes the sha256 of each script of the build context,
+// the digest of a single file script is the one of the file, the digest of a
+// directory is the one of the sha256sum listing of its files. It fails on the
+// first script not matching its expected digest, or writes the digests to the
+// termination message. The kubelet keeps the last 4096 bytes of the message,
+// so only the digests fitting in them are written, in the order of the
+// scripts, rather than cutting the first one.
+const verifyScriptsCommand = `size=0
+for entry in "$@"; do
+  name="${entry%%=*}"; expected="${entry#*=}"; dir="/context/$name"
+  if [ ! -d "$dir" ]; then
+    if [ -n "$expected" ]; then echo "$name: not found in the build context" > /dev/termination-log; exit 1; fi
+    continue
+  fi
+  if [ "$(find "$dir" -type f | wc -l)" -eq 1 ]; then
+    actual=$(sha256sum "$(find "$dir" -type f)" | cut -d ' ' -f 1)
+  else
+    actual=$(cd "$dir" && find . -type f -print0 | sort -z | xargs -0 sha256sum | sha256sum | cut -d ' ' -f 1)
+  fi
+  if [ -n "$expected" ] && [ "$actual" != "$expected" ]; then
+    echo "$name: expected sha256 $expected, got $actual" > /dev/termination-log; exit 1
+  fi
+  line="$name=$actual"
+  if [ $((size + ${#line} + 1)) -le 4096 ]; then
+    echo "$line" >> /tmp/digests; size=$((size + ${#line} + 1))
+  fi
+done
+cat /tmp/digests > /dev/termination-log 2> /dev/null || true`
 
-func generateDownloadFilesContainer(instance *apiv1.JobBuilder) v1.Container {
+func generateVerifyScriptsContainer(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) v1.Container {
+	args := []string{verifyScriptsCommand, "verify-scripts"}
+	for _, script := range scripts {
+		args = append(args, script.ScriptName+"="+script.Sha256)
+	}
 	return v1.Container{
-		Name:            "download-files",
-		Image:           "clementreiffers/s3-downloader-capnp-generator:v3",
+		Name:            "verify-scripts",
+		Image:           settings.Images.Busybox,
 		ImagePullPolicy: "IfNotPresent",
-		Env:             generateAwsConfig(),
-		VolumeMounts: []v1.VolumeMount{
-			{Name: "s3-config", MountPath: "/root/.aws", ReadOnly: true},
-			{Name: "context", MountPath: "/context"},
-		},
-		Command: []string{"./s3-download-files-capnp-generator"},
-		Args: []string{
-			"--s3-bucket-name", "$(AWS_BUCKET)",
-			"--s3-endpoint", "$(AWS_ENDPOINT)",
-			"--s3-region", "fr-par",
-			"--destination", "/context",
-			"--s3-object-key", strings.Join(instance.Spec.ScriptUrls, ","),
-		},
+		VolumeMounts:    []v1.VolumeMount{{Name: "context", MountPath: "/context", ReadOnly: true}},
+		Command:         []string{"sh", "-c"},
+		Args:            args,
 	}
 }
 
-func generateGettingDockerfile() v1.Container {
+// getVerifiedDigests reads the digests of the scripts verified by a build pod.
+func getVerifiedDigests(pod *v1.Pod) map[string]string {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != "verify-scripts" || status.State.Terminated == nil || status.State.Terminated.ExitCode != 0 {
+			continue
+		}
+		digests := map[string]string{}
+		for _, line := range strings.Split(strings.TrimSpace(status.State.Terminated.Message), "\n") {
+			if name, digest, ok := strings.Cut(line, "="); ok {
+				digests[name] = digest
+			}
+		}
+		return digests
+	}
+	return nil
+}
+
+// getVerifyFailure returns the script that failed the verification of a
+// build pod, if any.
+func getVerifyFailure(pod *v1.Pod) string {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == "verify-scripts" && status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 {
+			return strings.TrimSpace(status.State.Terminated.Message)
+		}
+	}
+	return ""
+}
+
+// generateCopyBuildConfig copies the files rendered by the operator, the
+// workerd configuration and the Dockerfile of a build, into the context.
+func generateCopyBuildConfig(settings *OperatorSettings) v1.Container {
 	return v1.Container{
-		Name:            "getting-dockerfile",
-		Image:           "curlimages/curl",
+		Name:            "copy-build-config",
+		Image:           settings.Images.Busybox,
 		ImagePullPolicy: "IfNotPresent",
 		VolumeMounts: []v1.VolumeMount{
-			{Name: "context", MountPath: "/context", ReadOnly: false},
+			getContextVolumeMount(),
+			{Name: "build-config", MountPath: "/build-config", ReadOnly: true},
 		},
-		Command: []string{"curl"},
-		Args:    []string{"-o", "/context/Dockerfile", "-L", "https://raw.githubusercontent.com/clementreiffers/Cf-Kubernetes-Arch/main/workerd.Dockerfile"},
+		Command: []string{"sh", "-c"},
+		// the glob skips the hidden directories of the configmap volume
+		Args: []string{"cp -L /build-config/* /context/"},
 	}
 }
 
-func generateKaniko(instance *apiv1.JobBuilder) v1.Container {
+// generateValidateScriptsContainer compiles the workerd configuration of the
+// build context before it is built, the errors of workerd end up in the
+// termination message.
+func generateValidateScriptsContainer(settings *OperatorSettings) v1.Container {
 	return v1.Container{
-		Name:  "kaniko",
-		Image: "gcr.io/kaniko-project/executor:latest",
-		Args: []string{
-			"--dockerfile=Dockerfile",
-			"--context=/context",
-			fmt.Sprintf("--destination=%s", instance.Spec.TargetImage),
-		},
-		VolumeMounts: []v1.VolumeMount{
-			{Name: "registry-credentials", MountPath: "/kaniko/.docker/", ReadOnly: true},
-			{Name: "context", MountPath: "/context"},
-		},
+		Name:                     "validate-scripts",
+		Image:                    settings.Images.WorkerdBuilder,
+		ImagePullPolicy:          "IfNotPresent",
+		VolumeMounts:             []v1.VolumeMount{{Name: "context", MountPath: "/context", ReadOnly: true}},
+		WorkingDir:               "/context",
+		TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
+		Command:                  []string{"sh", "-c"},
+		// the compiled binary is only built by the builder
+		Args: []string{"workerd compile config.capnp > /dev/null"},
 	}
 }
 
-func generateVolumes() []v1.Volume {
-	return []v1.Volume{
-		{
-			Name: "registry-credentials",
-			VolumeSource: v1.VolumeSource{
-				Projected: &v1.ProjectedVolumeSource{
-					Sources: []v1.VolumeProjection{
-						{
-							Secret: &v1.SecretProjection{
-								LocalObjectReference: v1.LocalObjectReference{
-									Name: "docker-hub",
-								},
-								Items: []v1.KeyToPath{
-									{Key: ".dockerconfigjson", Path: "config.json"},
-								},
+// getValidationFailure returns the output of the validation of the scripts of
+// a build pod when it failed.
+func getValidationFailure(pod *v1.Pod) string {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == "validate-scripts" && status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 {
+			if message := strings.TrimSpace(status.State.Terminated.Message); message != "" {
+				return message
+			}
+			return fmt.Sprintf("workerd compile exited with code %d", status.State.Terminated.ExitCode)
+		}
+	}
+	return ""
+}
+
+// getPinnedImage replaces the tag of image with digest.
+func getPinnedImage(image string, digest string) string {
+	if tag := strings.LastIndex(image, ":"); tag > strings.LastIndex(image, "/") {
+		image = image[:tag]
+	}
+	return image + "@" + digest
+}
+
+// generateRegistryCredentialsVolume holds the docker config.json of the
+// registry credentials of the operator.
+func generateRegistryCredentialsVolume(settings *OperatorSettings) v1.Volume {
+	return v1.Volume{
+		Name: "registry-credentials",
+		VolumeSource: v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{
+				Sources: []v1.VolumeProjection{
+					{
+						Secret: &v1.SecretProjection{
+							LocalObjectReference: v1.LocalObjectReference{
+								Name: settings.Registry.CredentialsSecret,
+							},
+							Items: []v1.KeyToPath{
+								{Key: ".dockerconfigjson", Path: "config.json"},
 							},
 						},
 					},
 				},
 			},
 		},
-		{
-			Name: "s3-config",
-			VolumeSource: v1.VolumeSource{
-				Projected: &v1.ProjectedVolumeSource{
-					Sources: []v1.VolumeProjection{
-						{
-							Secret: &v1.SecretProjection{
-								LocalObjectReference: v1.LocalObjectReference{Name: "s3-credentials"},
-								Items: []v1.KeyToPath{
-									{Key: "credentials", Path: "credentials"},
-								},
+	}
+}
+
+// generateS3ConfigVolume holds the aws-cli credentials and configuration of
+// the storage of the operator.
+func generateS3ConfigVolume(settings *OperatorSettings) v1.Volume {
+	return v1.Volume{
+		Name: "s3-config",
+		VolumeSource: v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{
+				Sources: []v1.VolumeProjection{
+					{
+						Secret: &v1.SecretProjection{
+							LocalObjectReference: v1.LocalObjectReference{Name: settings.Storage.CredentialsSecret},
+							Items: []v1.KeyToPath{
+								{Key: "credentials", Path: "credentials"},
 							},
 						},
-						{
-							ConfigMap: &v1.ConfigMapProjection{
-								LocalObjectReference: v1.LocalObjectReference{Name: "aws-config"},
-								Items: []v1.KeyToPath{
-									{Key: "config", Path: "config"},
-								},
-								Optional: nil,
+					},
+					{
+						ConfigMap: &v1.ConfigMapProjection{
+							LocalObjectReference: v1.LocalObjectReference{Name: settings.Storage.ConfigMap},
+							Items: []v1.KeyToPath{
+								{Key: "config", Path: "config"},
 							},
+							Optional: nil,
 						},
 					},
 				},
 			},
 		},
+	}
+}
+
+// generateContextVolumes returns the build context and the workerd
+// configuration read from configMapName.
+func generateContextVolumes(configMapName string) []v1.Volume {
+	return []v1.Volume{
 		{
 			Name: "context",
 			VolumeSource: v1.VolumeSource{
 				EmptyDir: &v1.EmptyDirVolumeSource{},
 			},
 		},
+		{
+			Name: "build-config",
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+				},
+			},
+		},
 	}
 }
 
-func createJob(instance *apiv1.JobBuilder) batchv1.Job {
+// generateVolumes returns the volumes used to fetch scripts into the context,
+// the workerd configuration being read from configMapName.
+func generateVolumes(configMapName string, settings *OperatorSettings) []v1.Volume {
+	return append([]v1.Volume{
+		generateRegistryCredentialsVolume(settings),
+		generateS3ConfigVolume(settings),
+	}, generateContextVolumes(configMapName)...)
+}
+
+// generateContextPodTemplate returns the pod fetching the scripts and the
+// files rendered by the operator into the build context, then validating
+// them, before builder builds it.
+func generateContextPodTemplate(instance *apiv1.JobBuilder, settings *OperatorSettings, builder v1.Container, volumes ...v1.Volume) (v1.PodTemplateSpec, error) {
+	sourceContainers, sourceVolumes, err := generateSourceContainers(instance.Spec.Scripts, settings)
+	if err != nil {
+		return v1.PodTemplateSpec{}, err
+	}
+	buildName := getBuildName(instance, getBuildNamespace(instance, settings))
+	volumes = append(append(generateVolumes(getBuildConfigMapName(buildName), settings), sourceVolumes...), volumes...)
+	return v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			InitContainers: append(sourceContainers, generateVerifyScriptsContainer(instance.Spec.Scripts, settings), generateCopyBuildConfig(settings), generateValidateScriptsContainer(settings)),
+			Containers:     []v1.Container{builder},
+			Volumes:        volumes,
+			RestartPolicy:  "Never",
+		},
+	}, nil
+}
+
+func createJob(instance *apiv1.JobBuilder, builder imageBuilder, settings *OperatorSettings, attempt int32) (batchv1.Job, error) {
+	template, err := builder.podTemplate(instance, settings)
+	if err != nil {
+		return batchv1.Job{}, err
+	}
 	ttl := int32(3600)
+	// the reconciler retries the transient failures with a new Job
+	backoffLimit := int32(0)
 	return batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      getJobName(instance.Name),
-			Namespace: "default",
+			Name:      getJobName(getBuildName(instance, getBuildNamespace(instance, settings)), attempt),
+			Namespace: instance.GetNamespace(),
 		},
 		Spec: batchv1.JobSpec{
 			//Parallelism: new(int32),
 			//Completions: new(int32),
 			TTLSecondsAfterFinished: &ttl,
-			Template: v1.PodTemplateSpec{
-				Spec: v1.PodSpec{
-					InitContainers: []v1.Container{
-						generateDownloadFilesContainer(instance),
-						generateGettingDockerfile(),
-					},
-					Containers: []v1.Container{
-						generateKaniko(instance),
-					},
-					Volumes:       generateVolumes(),
-					RestartPolicy: "Never",
-				},
-			},
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   instance.Spec.ActiveDeadlineSeconds,
+			Template:                template,
 		},
-	}
+	}, nil
 }
@@ -0,0 +1,23 @@
+package controllers
+
+import "testing"
+
+func TestGetPinnedImage(t *testing.T) {
+	digest := "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "clementreiffers/build-acme:1234", want: "clementreiffers/build-acme@" + digest},
+		{image: "clementreiffers/build-acme", want: "clementreiffers/build-acme@" + digest},
+		{image: "registry.example.com:5000/workers/build-acme:1234", want: "registry.example.com:5000/workers/build-acme@" + digest},
+		{image: "registry.example.com:5000/workers/build-acme", want: "registry.example.com:5000/workers/build-acme@" + digest},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			if got := getPinnedImage(test.image, digest); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -18,27 +18,60 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	apiv1 "operators/WorkerBundle/api/v1"
 )
 
+const (
+	// jobBuilderAnnotation names the JobBuilder, as namespace/name, of the
+	// resources of a build, which may run in another namespace.
+	jobBuilderAnnotation = "api.cf-worker/jobbuilder"
+	// jobBuilderFinalizer deletes the resources of a build running in another
+	// namespace, where they cannot be owned by the JobBuilder.
+	jobBuilderFinalizer = "api.cf-worker/build-cleanup"
+)
+
 // JobBuilderReconciler reconciles a JobBuilder object
 type JobBuilderReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Config *OperatorConfig
+	// BuildTimeout is how long a build Job may run before the JobBuilder fails.
+	BuildTimeout time.Duration
+	// Clientset reads the logs of the build pods.
+	Clientset kubernetes.Interface
+	// Recorder reports the builds as Events of their JobBuilder.
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=api.cf-worker,resources=jobbuilders,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=api.cf-worker,resources=jobbuilders/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=api.cf-worker,resources=jobbuilders/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -49,7 +82,7 @@ type JobBuilderReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
-func jobBuilderApplyResource(r *JobBuilderReconciler, ctx context.Context, resource client.Object, foundResource client.Object) error {
+func jobBuilderApplyResource(r *JobBuilderReconciler, ctx context.Context, instance *apiv1.JobBuilder, resource client.Object, foundResource client.Object) error {
 	err := r.Get(ctx, types.NamespacedName{Name: resource.GetName(), Namespace: resource.GetNamespace()}, foundResource)
 	if err != nil && errors.IsNotFound(err) {
 		err = r.Create(ctx, resource)
@@ -58,15 +91,45 @@ func jobBuilderApplyResource(r *JobBuilderReconciler, ctx context.Context, resou
 		}
 		return nil
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	if !isBuildResourceOf(foundResource, instance) {
+		return fmt.Errorf("%s/%s already exists for another JobBuilder", foundResource.GetNamespace(), foundResource.GetName())
+	}
+	return nil
 }
 
-func generateWorkers(scriptNames []string) []apiv1.Worker {
+// isBuildResourceOf reports whether a build resource was created for
+// instance, resources created before they were annotated are controlled by
+// their JobBuilder.
+func isBuildResourceOf(resource client.Object, instance *apiv1.JobBuilder) bool {
+	if jobBuilder, ok := resource.GetAnnotations()[jobBuilderAnnotation]; ok {
+		return jobBuilder == client.ObjectKeyFromObject(instance).String()
+	}
+	return metav1.IsControlledBy(resource, instance)
+}
+
+// generateWorkers lists one worker per built script, keeping the secret
+// settings of the workers already declared on the bundle unless the release
+// sets the secret of the script.
+func generateWorkers(scripts []apiv1.JobBuilderScript, secretRefs map[string]string, current []apiv1.Worker) []apiv1.Worker {
+	currentByName := make(map[string]apiv1.Worker, len(current))
+	for _, worker := range current {
+		currentByName[worker.WorkerName] = worker
+	}
+
 	var workers []apiv1.Worker
-	for index, scriptName := range scriptNames {
+	for _, script := range scripts {
+		secretRef, ok := secretRefs[script.ScriptName]
+		if !ok {
+			secretRef = currentByName[script.ScriptName].SecretRef
+		}
 		workers = append(workers, apiv1.Worker{
-			WorkerName:   scriptName,
-			WorkerNumber: int32(8080 + index),
+			WorkerName:   script.ScriptName,
+			WorkerNumber: script.Port,
+			EnvPrefix:    currentByName[script.ScriptName].EnvPrefix,
+			SecretRef:    secretRef,
 		})
 	}
 
@@ -74,8 +137,6 @@ func generateWorkers(scriptNames []string) []apiv1.Worker {
 }
 
 func (r *JobBuilderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := log.Log.WithValues("JobBuilder", req.NamespacedName)
-
 	instance := &apiv1.JobBuilder{}
 	err := r.Get(ctx, req.NamespacedName, instance)
 
@@ -86,54 +147,466 @@ func (r *JobBuilderReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	job := createJob(instance)
-	err = jobBuilderApplyResource(r, ctx, &job, &batchv1.Job{})
+	if !instance.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, instance)
+	}
+
+	switch instance.Status.Phase {
+	case apiv1.JobBuilderSucceeded, apiv1.JobBuilderFailed, apiv1.JobBuilderSuperseded:
+		return ctrl.Result{}, nil
+	case apiv1.JobBuilderBuilding:
+		return r.checkBuild(ctx, instance)
+	default:
+		return r.startBuild(ctx, instance)
+	}
+}
+
+// resolveSettings returns the operator settings of a JobBuilder, with the
+// credentials of its spec.
+func (r *JobBuilderReconciler) resolveSettings(instance *apiv1.JobBuilder) OperatorSettings {
+	settings := r.Config.Resolve(instance.GetNamespace(), getAccount(instance))
+	applyCredentials(&settings, instance.Spec.Credentials)
+	return settings
+}
+
+// applyCredentials replaces the secrets of the operator with the ones of an
+// account.
+func applyCredentials(settings *OperatorSettings, credentials apiv1.BuildCredentials) {
+	if credentials.RegistrySecretRef != "" {
+		settings.Registry.CredentialsSecret = credentials.RegistrySecretRef
+	}
+	if credentials.StorageSecretRef != "" {
+		settings.Storage.CredentialsSecret = credentials.StorageSecretRef
+	}
+}
+
+// getBuildNamespace returns the namespace where the build Job of a JobBuilder
+// is created.
+func getBuildNamespace(instance *apiv1.JobBuilder, settings *OperatorSettings) string {
+	if settings.BuildNamespace != "" {
+		return settings.BuildNamespace
+	}
+	return instance.GetNamespace()
+}
+
+// getJobNamespace returns the namespace of the build Job of a JobBuilder once
+// it is created, builds started before it was recorded run in the namespace of
+// their JobBuilder.
+func getJobNamespace(instance *apiv1.JobBuilder) string {
+	if instance.Status.JobNamespace != "" {
+		return instance.Status.JobNamespace
+	}
+	return instance.GetNamespace()
+}
+
+// ownBuildResource places a resource of the build in namespace, controlled by
+// the JobBuilder when they share a namespace.
+func (r *JobBuilderReconciler) ownBuildResource(instance *apiv1.JobBuilder, resource client.Object, namespace string) error {
+	resource.SetNamespace(namespace)
+	annotations := resource.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[jobBuilderAnnotation] = client.ObjectKeyFromObject(instance).String()
+	resource.SetAnnotations(annotations)
+	if namespace != instance.GetNamespace() {
+		return nil
+	}
+	return ctrl.SetControllerReference(instance, resource, r.Scheme)
+}
+
+func (r *JobBuilderReconciler) buildTimeout(instance *apiv1.JobBuilder) time.Duration {
+	if instance.Spec.BuildTimeout != nil {
+		return instance.Spec.BuildTimeout.Duration
+	}
+	return r.BuildTimeout
+}
+
+// startBuild renders the workerd configuration, creates the build Job and
+// moves the JobBuilder to Building, the Job watch brings the request back once
+// the Job makes progress.
+func (r *JobBuilderReconciler) startBuild(ctx context.Context, instance *apiv1.JobBuilder) (ctrl.Result, error) {
+	logger := log.Log.WithValues("JobBuilder", client.ObjectKeyFromObject(instance))
+
+	if instance.Status.StartTime != nil && time.Since(instance.Status.StartTime.Time) >= r.buildTimeout(instance) {
+		return ctrl.Result{}, r.failBuild(ctx, instance, "BuildTimeout", fmt.Sprintf("the build did not finish within %s", r.buildTimeout(instance)))
+	}
+	if instance.Status.NextAttemptTime != nil {
+		if wait := time.Until(instance.Status.NextAttemptTime.Time); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	schedule, err := r.scheduleBuild(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if schedule.SupersededBy != "" {
+		return ctrl.Result{}, r.supersedeBuild(ctx, instance, schedule.SupersededBy)
+	}
+	if schedule.Position > 0 {
+		return r.queueBuild(ctx, instance, schedule.Position)
+	}
+
+	settings := r.resolveSettings(instance)
+	namespace := getBuildNamespace(instance, &settings)
+	if namespace != instance.GetNamespace() && !controllerutil.ContainsFinalizer(instance, jobBuilderFinalizer) {
+		controllerutil.AddFinalizer(instance, jobBuilderFinalizer)
+		err = r.Update(ctx, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	configMap := createBuildConfigMap(instance, &settings)
+	err = r.ownBuildResource(instance, &configMap, namespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	err = jobBuilderApplyResource(r, ctx, instance, &configMap, &corev1.ConfigMap{})
+	if err != nil {
+		logger.Error(err, "unable to create the workerd configuration")
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "ConfigMapFailed", err)
+	}
+
+	backend := getBuilderBackend(instance, &settings)
+	builder, err := getImageBuilder(backend)
+	if err != nil {
+		logger.Error(err, "unable to select the builder")
+		return ctrl.Result{}, r.failBuild(ctx, instance, "InvalidBuilder", err.Error())
+	}
+	// the attempt is only counted once its Job is created
+	attempt := instance.Status.Attempts + 1
+	if instance.Status.Attempts == 0 && instance.Status.JobName != "" {
+		// builds started before the attempts were counted ran a single Job
+		attempt = 2
+	}
+	job, err := createJob(instance, builder, &settings, attempt)
+	if err != nil {
+		logger.Error(err, "unable to generate Job")
+		return ctrl.Result{}, r.failBuild(ctx, instance, "InvalidScriptUrl", err.Error())
+	}
+	err = r.ownBuildResource(instance, &job, namespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	err = jobBuilderApplyResource(r, ctx, instance, &job, &batchv1.Job{})
 	if err != nil {
 		logger.Error(err, "unable to create Job")
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "JobFailed", err)
+	}
+	logger.Info("Job created")
+	r.Recorder.Eventf(instance, corev1.EventTypeNormal, "BuildStarted", "started Job %s/%s with %s", job.Namespace, job.Name, backend)
+
+	now := metav1.Now()
+	instance.Status.ObservedGeneration = instance.Generation
+	instance.Status.Attempts = attempt
+	instance.Status.Phase = apiv1.JobBuilderBuilding
+	if instance.Status.StartTime == nil {
+		instance.Status.StartTime = &now
+	}
+	instance.Status.NextAttemptTime = nil
+	instance.Status.QueuePosition = 0
+	instance.Status.JobName = job.Name
+	instance.Status.JobNamespace = namespace
+	instance.Status.ConfigMapName = configMap.Name
+	instance.Status.Builder = backend
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionTrue, "JobRunning", "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, "JobRunning", "")
+	err = r.Status().Update(ctx, instance)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	return ctrl.Result{RequeueAfter: r.buildTimeout(instance) - time.Since(instance.Status.StartTime.Time)}, nil
+}
 
-	for {
-		foundJob := &batchv1.Job{}
-		err = r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.GetNamespace()}, foundJob)
+// queueBuild records the position of a build waiting for a free slot, the
+// request comes back when a build finishes.
+func (r *JobBuilderReconciler) queueBuild(ctx context.Context, instance *apiv1.JobBuilder, position int32) (ctrl.Result, error) {
+	message := fmt.Sprintf("waiting for a free build slot, position %d in the queue", position)
+	if instance.Status.Phase != apiv1.JobBuilderPending || instance.Status.QueuePosition != position {
+		instance.Status.Phase = apiv1.JobBuilderPending
+		instance.Status.QueuePosition = position
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionFalse, "Queued", message)
+		err := r.Status().Update(ctx, instance)
 		if err != nil {
-			logger.Error(err, "couldn't get the job status")
-		}
-		succeeded := foundJob.Status.Succeeded
-		failed := foundJob.Status.Failed
-		if succeeded == 1 && failed == 0 {
-			logger.Info("Job Successful") //update workerBundle here
-
-			bundle := &apiv1.WorkerBundle{}
-			err = r.Get(ctx, types.NamespacedName{Name: instance.Spec.WorkerBundleName, Namespace: instance.GetNamespace()}, bundle)
-
-			bundle.Spec = apiv1.WorkerBundleSpec{
-				DeploymentName: bundle.Spec.DeploymentName,
-				PodTemplate: apiv1.WorkerBundlePodTemplate{
-					Image:           instance.Spec.TargetImage,
-					ImagePullSecret: "",
-				},
-				Workers: generateWorkers(instance.Spec.ScriptNames),
-			}
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: queuedBuildRequeue}, nil
+}
+
+// supersedeBuild drops a queued build in favor of a newer build of the same
+// bundle, which is not a failure.
+func (r *JobBuilderReconciler) supersedeBuild(ctx context.Context, instance *apiv1.JobBuilder, supersededBy string) error {
+	message := fmt.Sprintf("the build was superseded by %s", supersededBy)
+	now := metav1.Now()
+	instance.Status.Phase = apiv1.JobBuilderSuperseded
+	instance.Status.CompletionTime = &now
+	instance.Status.QueuePosition = 0
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionFalse, "Superseded", message)
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, "Superseded", message)
+	meta.RemoveStatusCondition(&instance.Status.Conditions, apiv1.ConditionDegraded)
+	r.Recorder.Event(instance, corev1.EventTypeNormal, "Superseded", message)
+	return r.Status().Update(ctx, instance)
+}
+
+// isJobDeadlineExceeded reports whether a Job was stopped by its active
+// deadline.
+func isJobDeadlineExceeded(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue && condition.Reason == "DeadlineExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+func isJobFinished(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *JobBuilderReconciler) checkBuild(ctx context.Context, instance *apiv1.JobBuilder) (ctrl.Result, error) {
+	logger := log.Log.WithValues("JobBuilder", client.ObjectKeyFromObject(instance))
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: instance.Status.JobName, Namespace: getJobNamespace(instance)}, job)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Job deleted")
+			return ctrl.Result{}, r.failBuild(ctx, instance, "JobDeleted", "the build job was deleted")
+		}
+		return ctrl.Result{}, err
+	}
 
-			err = r.Update(ctx, bundle)
-			if err != nil {
-				return ctrl.Result{}, err
+	if isJobFinished(job, batchv1.JobComplete) {
+		logger.Info("Job Successful")
+		pods, err := r.getBuildPods(ctx, job)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		// the builder that started the Job reads its digest
+		builder, err := getImageBuilder(instance.Status.Builder)
+		if err != nil {
+			return ctrl.Result{}, r.failBuild(ctx, instance, "InvalidBuilder", err.Error())
+		}
+		digest := ""
+		var scriptDigests map[string]string
+		var cache *apiv1.JobBuilderCacheStatus
+		for i := range pods {
+			if podDigest := builder.digest(&pods[i]); podDigest != "" {
+				digest = podDigest
+				scriptDigests = getVerifiedDigests(&pods[i])
+				cache = r.getCacheStatus(ctx, instance, &pods[i])
+			}
+		}
+		instance.Status.ScriptDigests = scriptDigests
+		instance.Status.Cache = cache
+		return ctrl.Result{}, r.completeBuild(ctx, instance, digest)
+	}
+	if isJobFinished(job, batchv1.JobFailed) {
+		logger.Info("Job Failed")
+		pods, err := r.getBuildPods(ctx, job)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		pod := getLastPod(pods)
+		if pod != nil {
+			instance.Status.Containers = r.getContainerStatuses(ctx, pod)
+		}
+		for i := range pods {
+			if failure := getVerifyFailure(&pods[i]); failure != "" {
+				return ctrl.Result{}, r.failBuild(ctx, instance, "IntegrityCheckFailed", failure)
 			}
+			if failure := getValidationFailure(&pods[i]); failure != "" {
+				return ctrl.Result{}, r.failBuild(ctx, instance, "ValidationFailed", failure)
+			}
+		}
+		if isJobDeadlineExceeded(job) {
+			return ctrl.Result{}, r.failBuild(ctx, instance, "DeadlineExceeded", "the build job exceeded its active deadline")
+		}
+		message := describeFailure(instance.Status.Containers)
+		if isEvicted(pod) {
+			message = "the build pod was evicted: " + pod.Status.Message
+		}
+		if isTransientFailure(pod, instance.Status.Containers) && instance.Status.Attempts < getMaxAttempts(instance) {
+			return r.retryBuild(ctx, instance, message)
+		}
+		return ctrl.Result{}, r.failBuild(ctx, instance, "JobFailed", message)
+	}
 
-			logger.Info("successfully updated bundle!")
-			return ctrl.Result{}, nil
+	elapsed := time.Since(instance.Status.StartTime.Time)
+	timeout := r.buildTimeout(instance)
+	if elapsed >= timeout {
+		logger.Info("Job timed out", "timeout", timeout)
+		pods, err := r.getBuildPods(ctx, job)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if pod := getLastPod(pods); pod != nil {
+			instance.Status.Containers = r.getContainerStatuses(ctx, pod)
+		}
+		err = r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.failBuild(ctx, instance, "BuildTimeout", fmt.Sprintf("the build did not finish within %s", timeout))
+	}
+	return ctrl.Result{RequeueAfter: timeout - elapsed}, nil
+}
+
+// getBuildPods returns the pods of a build Job.
+func (r *JobBuilderReconciler) getBuildPods(ctx context.Context, job *batchv1.Job) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	err := r.List(ctx, pods, client.InNamespace(job.GetNamespace()), client.MatchingLabels{"job-name": job.Name})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// getContainerLogs returns the logs of a container of a pod, only its last
+// tailLines lines unless nil, cut after limitBytes bytes unless nil.
+func (r *JobBuilderReconciler) getContainerLogs(ctx context.Context, pod *corev1.Pod, container string, tailLines *int64, limitBytes *int64) (string, error) {
+	if r.Clientset == nil {
+		return "", fmt.Errorf("no clientset to read the logs")
+	}
+	options := &corev1.PodLogOptions{Container: container, TailLines: tailLines, LimitBytes: limitBytes}
+	logs, err := r.Clientset.CoreV1().Pods(pod.GetNamespace()).GetLogs(pod.Name, options).DoRaw(ctx)
+	return string(logs), err
+}
+
+// getCacheStatus summarizes the use of the layer cache by a kaniko build pod,
+// it is nil when the build did not use the cache.
+func (r *JobBuilderReconciler) getCacheStatus(ctx context.Context, instance *apiv1.JobBuilder, pod *corev1.Pod) *apiv1.JobBuilderCacheStatus {
+	settings := r.resolveSettings(instance)
+	if !isCacheEnabled(&settings) || (instance.Status.Builder != "" && instance.Status.Builder != apiv1.BackendKaniko) {
+		return nil
+	}
+	tailLines, limitBytes := cacheLogTailLines, maxCacheLogBytes
+	logs, err := r.getContainerLogs(ctx, pod, "kaniko", &tailLines, &limitBytes)
+	if err != nil {
+		log.Log.WithValues("JobBuilder", client.ObjectKeyFromObject(instance)).Info("unable to read the logs of the build", "error", err.Error())
+		return nil
+	}
+	return getKanikoCacheStatus(logs)
+}
+
+// completeBuild points the WorkerBundle at the freshly built image, pinned to
+// its digest so that each build rolls the pods out.
+func (r *JobBuilderReconciler) completeBuild(ctx context.Context, instance *apiv1.JobBuilder, digest string) error {
+	image := instance.Spec.TargetImage
+	if digest != "" {
+		image = getPinnedImage(image, digest)
+	} else {
+		log.Log.WithValues("JobBuilder", client.ObjectKeyFromObject(instance)).Info("digest of the build not found, using its tag")
+	}
+
+	err := updateBundleImage(ctx, r.Client, instance.GetNamespace(), instance.Spec.WorkerBundleName, image, instance.Spec.Scripts, instance.Spec.SecretRefs)
+	if err != nil {
+		return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "BundleUpdateFailed", err)
+	}
+	log.Log.WithValues("JobBuilder", client.ObjectKeyFromObject(instance)).Info("successfully updated bundle!")
+	r.Recorder.Eventf(instance, corev1.EventTypeNormal, "BuildSucceeded", "pushed %s", image)
+
+	now := metav1.Now()
+	instance.Status.Phase = apiv1.JobBuilderSucceeded
+	instance.Status.CompletionTime = &now
+	instance.Status.Image = image
+	instance.Status.Digest = digest
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionFalse, "JobSucceeded", "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionDegraded, metav1.ConditionFalse, "JobSucceeded", "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionTrue, "JobSucceeded", "")
+	return r.Status().Update(ctx, instance)
+}
+
+// retryBuild schedules a new attempt of a build that failed transiently, after
+// an exponential backoff.
+func (r *JobBuilderReconciler) retryBuild(ctx context.Context, instance *apiv1.JobBuilder, message string) (ctrl.Result, error) {
+	delay := getRetryDelay(instance.Status.Attempts)
+	next := metav1.NewTime(time.Now().Add(delay))
+	instance.Status.Phase = apiv1.JobBuilderPending
+	instance.Status.NextAttemptTime = &next
+	message = fmt.Sprintf("attempt %d of %d failed, retrying in %s: %s", instance.Status.Attempts, getMaxAttempts(instance), delay, message)
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionTrue, "RetryingBuild", message)
+	r.Recorder.Event(instance, corev1.EventTypeWarning, "BuildRetrying", message)
+	err := r.Status().Update(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+func (r *JobBuilderReconciler) failBuild(ctx context.Context, instance *apiv1.JobBuilder, reason string, message string) error {
+	now := metav1.Now()
+	instance.Status.Phase = apiv1.JobBuilderFailed
+	instance.Status.CompletionTime = &now
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionFalse, reason, "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionDegraded, metav1.ConditionTrue, reason, message)
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, reason, message)
+	r.Recorder.Event(instance, corev1.EventTypeWarning, reason, message)
+	return r.Status().Update(ctx, instance)
+}
+
+// finalize deletes the Job and the ConfigMap of a build running in another
+// namespace than its JobBuilder.
+func (r *JobBuilderReconciler) finalize(ctx context.Context, instance *apiv1.JobBuilder) error {
+	if !controllerutil.ContainsFinalizer(instance, jobBuilderFinalizer) {
+		return nil
+	}
+	namespace := getJobNamespace(instance)
+	resources := map[string]client.Object{
+		instance.Status.JobName:       &batchv1.Job{},
+		instance.Status.ConfigMapName: &corev1.ConfigMap{},
+	}
+	for name, resource := range resources {
+		if name == "" {
+			continue
+		}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, resource)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// never delete the build of a JobBuilder of another namespace
+		if !isBuildResourceOf(resource, instance) {
+			continue
+		}
+		err = r.Delete(ctx, resource, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	controllerutil.RemoveFinalizer(instance, jobBuilderFinalizer)
+	return r.Update(ctx, instance)
+}
 
-		} else if succeeded == 0 && failed == 1 {
-			logger.Info("Job Failed")
-			return ctrl.Result{}, nil // failed
+// findJobBuilderForJob enqueues the JobBuilder of a build Job, from its
+// annotation since the Job may run in another namespace.
+func (r *JobBuilderReconciler) findJobBuilderForJob(job client.Object) []reconcile.Request {
+	namespace, name, ok := strings.Cut(job.GetAnnotations()[jobBuilderAnnotation], "/")
+	if !ok {
+		owner := metav1.GetControllerOf(job)
+		if owner == nil || owner.Kind != "JobBuilder" {
+			return nil
 		}
+		namespace, name = job.GetNamespace(), owner.Name
 	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *JobBuilderReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&apiv1.JobBuilder{}).
+		Watches(&source.Kind{Type: &batchv1.Job{}}, handler.EnqueueRequestsFromMapFunc(r.findJobBuilderForJob)).
+		Watches(&source.Kind{Type: &apiv1.JobBuilder{}}, handler.EnqueueRequestsFromMapFunc(r.findQueuedJobBuilders)).
 		Complete(r)
 }
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+// failingJobClient fails the creation of Jobs, as an unavailable API server
+// would.
+type failingJobClient struct {
+	client.Client
+}
+
+func (c failingJobClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*batchv1.Job); ok {
+		return fmt.Errorf("the server is currently unable to handle the request")
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestStartBuildFailedJobCreation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	jobBuilder := &apiv1.JobBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "acme-1234", Namespace: "default", UID: types.UID("acme-1234"), Labels: map[string]string{accountLabel: "acme"}},
+		Spec: apiv1.JobBuilderSpec{
+			Scripts:          []apiv1.JobBuilderScript{{ScriptName: "hello", Url: "s3://bucket/hello", Port: 8080}},
+			TargetImage:      "clementreiffers/build-acme:1234",
+			WorkerBundleName: "acme",
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(jobBuilder).Build()
+	r := &JobBuilderReconciler{
+		Client:       failingJobClient{c},
+		Scheme:       scheme,
+		Config:       DefaultOperatorConfig(),
+		BuildTimeout: time.Hour,
+		Recorder:     record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+
+	instance := &apiv1.JobBuilder{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(jobBuilder), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.startBuild(ctx, instance); err == nil {
+		t.Fatal("the failed creation of the Job was not reported")
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(jobBuilder), instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.Attempts != 0 || instance.Status.Phase == apiv1.JobBuilderBuilding {
+		t.Errorf("the failed creation was counted as attempt %d in phase %s", instance.Status.Attempts, instance.Status.Phase)
+	}
+
+	r.Client = c
+	if _, err := r.startBuild(ctx, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.Attempts != 1 || instance.Status.JobName != getJobName(instance.Name, 1) {
+		t.Errorf("got attempt %d with Job %s, want attempt 1 with Job %s", instance.Status.Attempts, instance.Status.JobName, getJobName(instance.Name, 1))
+	}
+	job := &batchv1.Job{}
+	if err := c.Get(ctx, types.NamespacedName{Name: getJobName(instance.Name, 1), Namespace: "default"}, job); err != nil {
+		t.Errorf("the Job of the first attempt was not created: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+type StorageSettings struct {
+	Endpoint          string `json:"endpoint,omitempty"`
+	Bucket            string `json:"bucket,omitempty"`
+	Region            string `json:"region,omitempty"`
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	ConfigMap         string `json:"configMap,omitempty"`
+}
+
+type RegistrySettings struct {
+	ImagePrefix       string `json:"imagePrefix,omitempty"`
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+type ImageSettings struct {
+	Kaniko         string `json:"kaniko,omitempty"`
+	KanikoWarmer   string `json:"kanikoWarmer,omitempty"`
+	Curl           string `json:"curl,omitempty"`
+	AwsCli         string `json:"awsCli,omitempty"`
+	Placeholder    string `json:"placeholder,omitempty"`
+	Git            string `json:"git,omitempty"`
+	Busybox        string `json:"busybox,omitempty"`
+	Oras           string `json:"oras,omitempty"`
+	WorkerdBuilder string `json:"workerdBuilder,omitempty"`
+	WorkerdRunner  string `json:"workerdRunner,omitempty"`
+	// Workerd serves the scripts of the bundles in Mount mode.
+	Workerd  string `json:"workerd,omitempty"`
+	BuildKit string `json:"buildKit,omitempty"`
+	Crane    string `json:"crane,omitempty"`
+}
+
+// CacheSettings enable the layer cache of kaniko builds.
+type CacheSettings struct {
+	// Enabled caches the layers of the builds in Repository.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Repository stores the cached layers, kaniko derives it from the target
+	// image when empty.
+	Repository string `json:"repository,omitempty"`
+	// TTL is how long cached layers are reused, e.g. 168h.
+	TTL string `json:"ttl,omitempty"`
+	// ClaimName is a PersistentVolumeClaim caching the base images of the
+	// builds, it is warmed before each build.
+	ClaimName string `json:"claimName,omitempty"`
+}
+
+// BuildSettings limit the builds running at once, 0 means unlimited.
+type BuildSettings struct {
+	// MaxConcurrent is the number of builds running at once across accounts,
+	// it is only read from the top level of the configuration.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+	// MaxConcurrentPerAccount is the number of builds of an account running at
+	// once.
+	MaxConcurrentPerAccount int `json:"maxConcurrentPerAccount,omitempty"`
+}
+
+type IngressSettings struct {
+	Host string `json:"host,omitempty"`
+}
+
+// OperatorSettings are the infrastructure settings used to build and serve
+// workers. Empty fields of an override keep the value they override.
+type OperatorSettings struct {
+	Storage  StorageSettings  `json:"storage,omitempty"`
+	Registry RegistrySettings `json:"registry,omitempty"`
+	Images   ImageSettings    `json:"images,omitempty"`
+	Cache    CacheSettings    `json:"cache,omitempty"`
+	Ingress  IngressSettings  `json:"ingress,omitempty"`
+	Builds   BuildSettings    `json:"builds,omitempty"`
+	// Builder is the default builder of the JobBuilders, Kaniko or BuildKit.
+	// Prebuilt can only be set on a JobBuilder, whose target image is pushed
+	// beforehand.
+	Builder string `json:"builder,omitempty"`
+	// BuildNamespace runs the build Jobs of every JobBuilder in one namespace,
+	// by default they run in the namespace of their JobBuilder.
+	BuildNamespace string `json:"buildNamespace,omitempty"`
+}
+
+// OperatorSettingsOverride replaces settings for the resources of a namespace,
+// of an account, or of an account in a namespace.
+type OperatorSettingsOverride struct {
+	Namespace        string `json:"namespace,omitempty"`
+	Account          string `json:"account,omitempty"`
+	OperatorSettings `json:",inline"`
+}
+
+// OperatorConfig is the configuration file of the operator.
+type OperatorConfig struct {
+	OperatorSettings `json:",inline"`
+	Overrides        []OperatorSettingsOverride `json:"overrides,omitempty"`
+}
+
+func DefaultOperatorConfig() *OperatorConfig {
+	return &OperatorConfig{
+		OperatorSettings: OperatorSettings{
+			Storage: StorageSettings{
+				Endpoint:          "https://s3.fr-par.scw.cloud",
+				Bucket:            "stage-cf-worker",
+				Region:            "fr-par",
+				CredentialsSecret: "s3-credentials",
+				ConfigMap:         "aws-config",
+			},
+			Registry: RegistrySettings{
+				ImagePrefix:       "clementreiffers/build-",
+				CredentialsSecret: "docker-hub",
+			},
+			Images: ImageSettings{
+				Kaniko:         "gcr.io/kaniko-project/executor:latest",
+				KanikoWarmer:   "gcr.io/kaniko-project/warmer:latest",
+				Curl:           "curlimages/curl",
+				AwsCli:         "amazon/aws-cli",
+				Placeholder:    "nginx",
+				Git:            "alpine/git",
+				Busybox:        "busybox",
+				Oras:           "ghcr.io/oras-project/oras:v1.0.0",
+				WorkerdBuilder: "clementreiffers/worker-builder",
+				WorkerdRunner:  "clementreiffers/worker-runner",
+				Workerd:        "clementreiffers/worker-builder",
+				BuildKit:       "moby/buildkit:rootless",
+				Crane:          "gcr.io/go-containerregistry/crane:debug",
+			},
+			Ingress: IngressSettings{
+				Host: "worker.127.0.0.1.sslip.io",
+			},
+			Builds: BuildSettings{
+				MaxConcurrent:           5,
+				MaxConcurrentPerAccount: 1,
+			},
+			Builder: "Kaniko",
+		},
+	}
+}
+
+// LoadOperatorConfig reads the configuration file at path on top of the
+// defaults, an empty path only returns the defaults.
+func LoadOperatorConfig(path string) (*OperatorConfig, error) {
+	config := DefaultOperatorConfig()
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, config.validate()
+}
+
+// validate rejects the settings no JobBuilder of the operator can build with.
+func (c *OperatorConfig) validate() error {
+	settings := []OperatorSettings{c.OperatorSettings}
+	for _, override := range c.Overrides {
+		settings = append(settings, override.OperatorSettings)
+	}
+	for _, s := range settings {
+		switch apiv1.JobBuilderBackend(s.Builder) {
+		case "", apiv1.BackendKaniko, apiv1.BackendBuildKit:
+		case apiv1.BackendPrebuilt:
+			// the releases are built into images tagged with their hash, which
+			// cannot be pushed beforehand
+			return fmt.Errorf("the Prebuilt builder can only be set on a JobBuilder")
+		default:
+			return fmt.Errorf("unsupported builder %q", s.Builder)
+		}
+	}
+	return nil
+}
+
+func (o *OperatorSettingsOverride) matches(namespace string, account string) bool {
+	if o.Namespace == "" && o.Account == "" {
+		return false
+	}
+	return (o.Namespace == "" || o.Namespace == namespace) && (o.Account == "" || o.Account == account)
+}
+
+// Resolve returns the settings that apply to the resources of account in
+// namespace. Namespace overrides are applied first, then account overrides.
+func (c *OperatorConfig) Resolve(namespace string, account string) OperatorSettings {
+	if c == nil {
+		c = DefaultOperatorConfig()
+	}
+	settings := c.OperatorSettings
+	for _, byAccount := range []bool{false, true} {
+		for i := range c.Overrides {
+			override := &c.Overrides[i]
+			if (override.Account != "") != byAccount || !override.matches(namespace, account) {
+				continue
+			}
+			// unmarshalling onto the settings only replaces the fields set by the override
+			data, err := json.Marshal(override.OperatorSettings)
+			if err == nil {
+				_ = json.Unmarshal(data, &settings)
+			}
+		}
+	}
+	return settings
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	config := DefaultOperatorConfig()
+	config.Overrides = []OperatorSettingsOverride{
+		{Account: "acme", OperatorSettings: OperatorSettings{Storage: StorageSettings{Bucket: "acme-bucket"}}},
+		{Namespace: "team-a", OperatorSettings: OperatorSettings{Storage: StorageSettings{Bucket: "team-a-bucket", Region: "eu-west-1"}}},
+		{Namespace: "team-a", Account: "acme", OperatorSettings: OperatorSettings{Registry: RegistrySettings{ImagePrefix: "registry.team-a/acme-"}}},
+		{OperatorSettings: OperatorSettings{Storage: StorageSettings{Bucket: "ignored"}}},
+	}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		account     string
+		bucket      string
+		region      string
+		imagePrefix string
+	}{
+		{
+			name:        "defaults",
+			namespace:   "default",
+			bucket:      "stage-cf-worker",
+			region:      "fr-par",
+			imagePrefix: "clementreiffers/build-",
+		},
+		{
+			name:        "namespace override",
+			namespace:   "team-a",
+			account:     "other",
+			bucket:      "team-a-bucket",
+			region:      "eu-west-1",
+			imagePrefix: "clementreiffers/build-",
+		},
+		{
+			name:        "account override",
+			namespace:   "default",
+			account:     "acme",
+			bucket:      "acme-bucket",
+			region:      "fr-par",
+			imagePrefix: "clementreiffers/build-",
+		},
+		{
+			name:        "account overrides are applied after namespace overrides",
+			namespace:   "team-a",
+			account:     "acme",
+			bucket:      "acme-bucket",
+			region:      "eu-west-1",
+			imagePrefix: "registry.team-a/acme-",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := config.Resolve(test.namespace, test.account)
+			if settings.Storage.Bucket != test.bucket {
+				t.Errorf("got bucket %q, want %q", settings.Storage.Bucket, test.bucket)
+			}
+			if settings.Storage.Region != test.region {
+				t.Errorf("got region %q, want %q", settings.Storage.Region, test.region)
+			}
+			if settings.Registry.ImagePrefix != test.imagePrefix {
+				t.Errorf("got image prefix %q, want %q", settings.Registry.ImagePrefix, test.imagePrefix)
+			}
+		})
+	}
+
+	if config.OperatorSettings.Storage.Bucket != "stage-cf-worker" {
+		t.Errorf("Resolve modified the settings of the configuration")
+	}
+}
+
+func TestResolveNilConfig(t *testing.T) {
+	var config *OperatorConfig
+	settings := config.Resolve("default", "acme")
+	if settings.Images.Kaniko != DefaultOperatorConfig().Images.Kaniko {
+		t.Errorf("a nil configuration does not resolve to the defaults")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		builder   string
+		override  string
+		wantError bool
+	}{
+		{name: "default builder"},
+		{name: "kaniko", builder: "Kaniko"},
+		{name: "buildkit override", override: "BuildKit"},
+		{name: "prebuilt", builder: "Prebuilt", wantError: true},
+		{name: "prebuilt override", override: "Prebuilt", wantError: true},
+		{name: "unknown builder", builder: "Docker", wantError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultOperatorConfig()
+			config.Builder = test.builder
+			config.Overrides = []OperatorSettingsOverride{{Account: "acme", OperatorSettings: OperatorSettings{Builder: test.override}}}
+			if err := config.validate(); (err != nil) != test.wantError {
+				t.Errorf("got error %v, want error %t", err, test.wantError)
+			}
+		})
+	}
+}
@@ -13,24 +13,112 @@ func createPodPorts(ports []apiv1.Worker) []v1.ContainerPort {
 		podPorts[i] = v1.ContainerPort{
 			Name:          port.WorkerName,
 			ContainerPort: port.WorkerNumber,
+			Protocol:      v1.ProtocolTCP,
 		}
 	}
 	return podPorts
 }
 
+// createPodEnv exposes the secret of each worker to workerd, prefixed so that
+// workers sharing a secret do not collide.
+func createPodEnv(workers []apiv1.Worker) []v1.EnvFromSource {
+	var envFrom []v1.EnvFromSource
+	for _, worker := range workers {
+		if worker.SecretRef == "" {
+			continue
+		}
+		envFrom = append(envFrom, v1.EnvFromSource{
+			Prefix: getEnvPrefix(worker),
+			SecretRef: &v1.SecretEnvSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: worker.SecretRef},
+			},
+		})
+	}
+	return envFrom
+}
+
+func createPodImagePullSecrets(instance *apiv1.WorkerBundle) []v1.LocalObjectReference {
+	if instance.Spec.PodTemplate.ImagePullSecret == "" {
+		return nil
+	}
+	return []v1.LocalObjectReference{{Name: instance.Spec.PodTemplate.ImagePullSecret}}
+}
+
 func createPodSpec(instance *apiv1.WorkerBundle) v1.PodSpec {
 	return v1.PodSpec{
+		ImagePullSecrets: createPodImagePullSecrets(instance),
 		Containers: []v1.Container{
 			{
-				Name:  getPodName(instance.Spec.DeploymentName),
-				Image: instance.Spec.PodTemplate.Image,
-				Ports: createPodPorts(instance.Spec.Workers),
+				Name:    getPodName(instance.Spec.DeploymentName),
+				Image:   instance.Spec.PodTemplate.Image,
+				Ports:   createPodPorts(instance.Spec.Workers),
+				EnvFrom: createPodEnv(instance.Spec.Workers),
 			},
 		},
 	}
 }
 
-func createDeployment(instance *apiv1.WorkerBundle) appsv1.Deployment {
+// optionalVolume lets a pod start while the secrets and ConfigMaps projected
+// by volume are missing, only the containers reading them fail.
+func optionalVolume(volume v1.Volume) v1.Volume {
+	optional := true
+	for i := range volume.Projected.Sources {
+		if secret := volume.Projected.Sources[i].Secret; secret != nil {
+			secret.Optional = &optional
+		}
+		if configMap := volume.Projected.Sources[i].ConfigMap; configMap != nil {
+			configMap.Optional = &optional
+		}
+	}
+	return volume
+}
+
+// generateCredentialVolumes returns the credentials read by the sources of the
+// scripts of a bundle in Mount mode, which does not push any image.
+func generateCredentialVolumes(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) []v1.Volume {
+	var s3, oci bool
+	for _, script := range scripts {
+		source, _ := getScriptSource(script.Url)
+		switch source.(type) {
+		case s3Source:
+			s3 = true
+		case ociSource:
+			oci = true
+		}
+	}
+	var volumes []v1.Volume
+	if s3 {
+		volumes = append(volumes, optionalVolume(generateS3ConfigVolume(settings)))
+	}
+	if oci {
+		volumes = append(volumes, optionalVolume(generateRegistryCredentialsVolume(settings)))
+	}
+	return volumes
+}
+
+// createMountPodSpec runs a stock workerd image serving the scripts fetched
+// into the context by the init containers of the pod.
+func createMountPodSpec(instance *apiv1.WorkerBundle, settings *OperatorSettings) (v1.PodSpec, error) {
+	sourceContainers, sourceVolumes, err := generateSourceContainers(instance.Spec.Scripts, settings)
+	if err != nil {
+		return v1.PodSpec{}, err
+	}
+	spec := createPodSpec(instance)
+	spec.InitContainers = append(sourceContainers, generateVerifyScriptsContainer(instance.Spec.Scripts, settings), generateCopyBuildConfig(settings))
+	spec.Volumes = append(generateContextVolumes(getBuildConfigMapName(instance.Spec.DeploymentName)), generateCredentialVolumes(instance.Spec.Scripts, settings)...)
+	spec.Volumes = append(spec.Volumes, sourceVolumes...)
+	container := &spec.Containers[0]
+	container.Image = settings.Images.Workerd
+	container.Command = []string{"workerd", "serve", "/context/config.capnp"}
+	container.VolumeMounts = []v1.VolumeMount{{Name: "context", MountPath: "/context", ReadOnly: true}}
+	return spec, nil
+}
+
+// createDeployment builds the Deployment of a bundle, the annotations of the
+// pod template hash what the pods read when they start, the secrets, the
+// workerd configuration and the ConfigMaps of the scripts, so that pods are
+// restarted when they change.
+func createDeployment(instance *apiv1.WorkerBundle, podSpec v1.PodSpec, annotations map[string]string) appsv1.Deployment {
 	replicas := int32(1)
 	return appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{Namespace: instance.GetNamespace(), Name: getDeploymentName(instance.Spec.DeploymentName)},
@@ -41,10 +129,11 @@ func createDeployment(instance *apiv1.WorkerBundle) appsv1.Deployment {
 			},
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:   getPodName(instance.Spec.DeploymentName),
-					Labels: map[string]string{"app": getPodName(instance.Spec.DeploymentName)},
+					Name:        getPodName(instance.Spec.DeploymentName),
+					Labels:      map[string]string{"app": getPodName(instance.Spec.DeploymentName)},
+					Annotations: annotations,
 				},
-				Spec: createPodSpec(instance),
+				Spec: podSpec,
 			},
 		},
 	}
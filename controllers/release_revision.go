@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+const (
+	// authorAnnotation names who asked for a release, it is copied from the
+	// WorkerDeployment down to the revisions of the release.
+	authorAnnotation = "api.cf-worker/author"
+	// defaultReleaseHistoryLimit is used when no WorkerDeployment of the account
+	// sets a limit.
+	defaultReleaseHistoryLimit = 10
+)
+
+// releaseRevision is the content of the ControllerRevision recording a build
+// of the WorkerReleases of an account.
+type releaseRevision struct {
+	Image          string                   `json:"image"`
+	Scripts        []apiv1.JobBuilderScript `json:"scripts"`
+	WorkerVersions map[string]string        `json:"workerVersions"`
+	SecretRefs     map[string]string        `json:"secretRefs,omitempty"`
+	Author         string                   `json:"author,omitempty"`
+	// Credentials pushed the image, they delete it once the revision is pruned.
+	Credentials apiv1.BuildCredentials `json:"credentials,omitempty"`
+}
+
+func getReleaseRevisionName(account string, revision int64) string {
+	return account + "-" + strconv.FormatInt(revision, 10)
+}
+
+// createReleaseRevision records a build of the releases of account, credited
+// to author.
+func createReleaseRevision(account *apiv1.WorkerAccount, author string, jobBuilder *apiv1.JobBuilder, workerVersions map[string]string, revision int64) (appsv1.ControllerRevision, error) {
+	data, err := json.Marshal(releaseRevision{
+		Image:          jobBuilder.Status.Image,
+		Scripts:        jobBuilder.Spec.Scripts,
+		WorkerVersions: workerVersions,
+		SecretRefs:     jobBuilder.Spec.SecretRefs,
+		Author:         author,
+		Credentials:    jobBuilder.Spec.Credentials,
+	})
+	if err != nil {
+		return appsv1.ControllerRevision{}, err
+	}
+	var finalizers []string
+	// a prebuilt image was pushed by someone else, who deletes it
+	if jobBuilder.Spec.Builder != apiv1.BackendPrebuilt {
+		finalizers = []string{revisionImageFinalizer}
+	}
+	return appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getReleaseRevisionName(account.Name, revision),
+			Namespace: account.GetNamespace(),
+			Labels: map[string]string{
+				accountLabel: account.Name,
+			},
+			Annotations: map[string]string{
+				releaseHashAnnotation: jobBuilder.Annotations[releaseHashAnnotation],
+			},
+			Finalizers: finalizers,
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: revision,
+	}, nil
+}
+
+// listReleaseRevisions returns the revisions of the releases of an account,
+// oldest first. The pruned revisions whose image is being deleted are left
+// out.
+func listReleaseRevisions(ctx context.Context, c client.Client, account *apiv1.WorkerAccount) ([]appsv1.ControllerRevision, error) {
+	list := &appsv1.ControllerRevisionList{}
+	err := c.List(ctx, list, client.InNamespace(account.GetNamespace()), client.MatchingLabels{accountLabel: account.Name})
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]appsv1.ControllerRevision, 0, len(list.Items))
+	for _, revision := range list.Items {
+		if revision.DeletionTimestamp.IsZero() {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// findReleaseRevision returns the latest revision built from the releases
+// hashing to hash, nil when they were never built.
+func findReleaseRevision(ctx context.Context, c client.Client, account *apiv1.WorkerAccount, hash string) (*appsv1.ControllerRevision, error) {
+	revisions, err := listReleaseRevisions(ctx, c, account)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].Annotations[releaseHashAnnotation] == hash {
+			return &revisions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// getRollbackTo returns the revision the releases of an account roll back to,
+// 0 when none of them sets rollbackTo.
+func getRollbackTo(releases []apiv1.WorkerRelease) (int64, error) {
+	rollbackTo := int64(0)
+	for _, release := range releases {
+		if release.Spec.RollbackTo == 0 || release.Spec.RollbackTo == rollbackTo {
+			continue
+		}
+		if rollbackTo != 0 {
+			return 0, fmt.Errorf("the releases of the account roll back to both revision %d and revision %d", rollbackTo, release.Spec.RollbackTo)
+		}
+		rollbackTo = release.Spec.RollbackTo
+	}
+	return rollbackTo, nil
+}
+
+func decodeReleaseRevision(revision *appsv1.ControllerRevision) (releaseRevision, error) {
+	content := releaseRevision{}
+	err := json.Unmarshal(revision.Data.Raw, &content)
+	return content, err
+}
+
+// getReleaseHistoryLimit returns the largest releaseHistoryLimit of the
+// WorkerDeployments of the releases built together.
+func getReleaseHistoryLimit(ctx context.Context, c client.Client, releases []apiv1.WorkerRelease) (int, error) {
+	deployments := &apiv1.WorkerDeploymentList{}
+	err := c.List(ctx, deployments, client.InNamespace(releases[0].GetNamespace()))
+	if err != nil {
+		return 0, err
+	}
+	accounts := map[string]bool{}
+	for _, release := range releases {
+		accounts[release.Spec.Accounts] = true
+	}
+	limit := -1
+	for _, deployment := range deployments.Items {
+		if accounts[deployment.Spec.Accounts] && int(deployment.Spec.ReleaseHistoryLimit) > limit {
+			limit = int(deployment.Spec.ReleaseHistoryLimit)
+		}
+	}
+	if limit < 0 {
+		return defaultReleaseHistoryLimit, nil
+	}
+	return limit, nil
+}
+
+// pruneReleaseRevisions deletes the oldest revisions beyond the current one
+// and limit past ones, never deleting the revision being served.
+func pruneReleaseRevisions(ctx context.Context, c client.Client, revisions []appsv1.ControllerRevision, served int64, limit int) error {
+	extra := len(revisions) - (limit + 1)
+	for i := 0; i < len(revisions) && extra > 0; i++ {
+		if revisions[i].Revision == served {
+			continue
+		}
+		err := c.Delete(ctx, &revisions[i])
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		extra--
+	}
+	return nil
+}
+
+// updateBundleImage points a WorkerBundle at an image and at the workers the
+// image serves.
+func updateBundleImage(ctx context.Context, c client.Client, namespace string, bundleName string, image string, scripts []apiv1.JobBuilderScript, secretRefs map[string]string) error {
+	bundle := &apiv1.WorkerBundle{}
+	err := c.Get(ctx, types.NamespacedName{Name: bundleName, Namespace: namespace}, bundle)
+	if err != nil {
+		return err
+	}
+	workers := generateWorkers(scripts, secretRefs, bundle.Spec.Workers)
+	if bundle.Spec.PodTemplate.Image == image && equalWorkers(bundle.Spec.Workers, workers) && len(bundle.Spec.Scripts) == 0 {
+		return nil
+	}
+	bundle.Spec.PodTemplate.Image = image
+	bundle.Spec.Workers = workers
+	// the scripts are only fetched by the pods in Mount mode
+	bundle.Spec.Scripts = nil
+	return c.Update(ctx, bundle)
+}
+
+func equalWorkers(a []apiv1.Worker, b []apiv1.Worker) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+// getReleaseSelector returns the selector of the WorkerReleases of an account,
+// an empty selector selects the releases labelled with the account name.
+func getReleaseSelector(account *apiv1.WorkerAccount) (labels.Selector, error) {
+	selector := account.Spec.WorkerReleaseSelector
+	if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+		return labels.SelectorFromSet(labels.Set{accountLabel: account.Name}), nil
+	}
+	return metav1.LabelSelectorAsSelector(&selector)
+}
+
+// listAccountReleases returns the WorkerReleases selected by an account,
+// sorted by name.
+func listAccountReleases(ctx context.Context, c client.Client, account *apiv1.WorkerAccount) ([]apiv1.WorkerRelease, error) {
+	selector, err := getReleaseSelector(account)
+	if err != nil {
+		return nil, err
+	}
+	releases := &apiv1.WorkerReleaseList{}
+	err = c.List(ctx, releases, client.InNamespace(account.GetNamespace()), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var selected []apiv1.WorkerRelease
+	for _, release := range releases.Items {
+		if release.DeletionTimestamp.IsZero() {
+			selected = append(selected, release)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+	return selected, nil
+}
+
+// listReleaseAccounts returns the WorkerAccounts selecting a release, sorted
+// by name.
+func listReleaseAccounts(ctx context.Context, c client.Client, release client.Object) ([]apiv1.WorkerAccount, error) {
+	accounts := &apiv1.WorkerAccountList{}
+	err := c.List(ctx, accounts, client.InNamespace(release.GetNamespace()))
+	if err != nil {
+		return nil, err
+	}
+	var selecting []apiv1.WorkerAccount
+	for _, account := range accounts.Items {
+		selector, err := getReleaseSelector(&account)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(release.GetLabels())) {
+			selecting = append(selecting, account)
+		}
+	}
+	sort.Slice(selecting, func(i, j int) bool {
+		return selecting[i].Name < selecting[j].Name
+	})
+	return selecting, nil
+}
+
+// releaseScripts are the scripts of the releases of an account, built
+// together into a single image.
+type releaseScripts struct {
+	WorkerVersions map[string]string `json:"workerVersions"`
+	// secrets are read at runtime and do not take part in the build
+	SecretRefs         map[string]string `json:"-"`
+	Checksums          map[string]string `json:"checksums,omitempty"`
+	CompatibilityDates map[string]string `json:"compatibilityDates,omitempty"`
+	MainModules        map[string]string `json:"mainModules,omitempty"`
+	// Bindings are the keys of the secret of each script, they are compiled
+	// into the workerd configuration
+	Bindings map[string][]string `json:"bindings,omitempty"`
+}
+
+// mergeReleases aggregates the scripts of the releases of an account, the
+// first release declaring a script wins.
+func mergeReleases(releases []apiv1.WorkerRelease) releaseScripts {
+	scripts := releaseScripts{
+		WorkerVersions:     map[string]string{},
+		SecretRefs:         map[string]string{},
+		Checksums:          map[string]string{},
+		CompatibilityDates: map[string]string{},
+		MainModules:        map[string]string{},
+		Bindings:           map[string][]string{},
+	}
+	for _, release := range releases {
+		for scriptName, url := range release.Spec.WorkerVersions {
+			if _, ok := scripts.WorkerVersions[scriptName]; ok {
+				continue
+			}
+			scripts.WorkerVersions[scriptName] = url
+			if secretRef, ok := release.Spec.SecretRefs[scriptName]; ok {
+				scripts.SecretRefs[scriptName] = secretRef
+			}
+			if checksum, ok := release.Spec.Checksums[scriptName]; ok {
+				scripts.Checksums[scriptName] = checksum
+			}
+			if compatibilityDate, ok := release.Spec.CompatibilityDates[scriptName]; ok {
+				scripts.CompatibilityDates[scriptName] = compatibilityDate
+			}
+			if mainModule, ok := release.Spec.MainModules[scriptName]; ok {
+				scripts.MainModules[scriptName] = mainModule
+			}
+		}
+	}
+	return scripts
+}
+
+// getReleasesHash hashes the scripts of the releases built together, a new
+// build is only needed when it changes.
+func getReleasesHash(scripts releaseScripts) string {
+	// maps are marshalled with sorted keys
+	data, _ := json.Marshal(scripts)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 clementreiffers.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// revisionImageFinalizer deletes the image of a revision from the registry
+	// once the revision is pruned or its account deleted.
+	revisionImageFinalizer = "api.cf-worker/registry-image"
+	// revisionAnnotation names the revision, as namespace/name, of the Job
+	// deleting its image, which may run in another namespace.
+	revisionAnnotation = "api.cf-worker/revision"
+)
+
+// ReleaseRevisionReconciler deletes the images of the release revisions being
+// deleted.
+type ReleaseRevisionReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *OperatorConfig
+}
+
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create
+
+// Reconcile runs a Job deleting the image of a revision being deleted, and
+// releases the revision once the Job is finished.
+func (r *ReleaseRevisionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.Log.WithValues("ControllerRevision", req.NamespacedName)
+
+	instance := &appsv1.ControllerRevision{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if instance.DeletionTimestamp.IsZero() || !controllerutil.ContainsFinalizer(instance, revisionImageFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	content, err := decodeReleaseRevision(instance)
+	if err != nil || content.Image == "" {
+		logger.Error(err, "the revision holds no image to delete")
+		return ctrl.Result{}, r.release(ctx, instance)
+	}
+	settings := r.Config.Resolve(instance.GetNamespace(), getAccount(instance))
+	applyCredentials(&settings, content.Credentials)
+	job := createImageCleanupJob(instance, content.Image, &settings)
+
+	found := &batchv1.Job{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(&job), found)
+	if errors.IsNotFound(err) {
+		logger.Info("deleting the image of the revision", "image", content.Image)
+		return ctrl.Result{}, r.Create(ctx, &job)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	switch {
+	case isJobFinished(found, batchv1.JobComplete):
+		logger.Info("image deleted", "image", content.Image)
+	case isJobFinished(found, batchv1.JobFailed):
+		// the image is left in the registry rather than blocking the deletion
+		// of the revision, and of its account, forever
+		logger.Info("unable to delete the image, see the logs of the Job", "image", content.Image, "job", client.ObjectKeyFromObject(found))
+	default:
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, r.release(ctx, instance)
+}
+
+// release removes the finalizer of a revision, which is then deleted.
+func (r *ReleaseRevisionReconciler) release(ctx context.Context, instance *appsv1.ControllerRevision) error {
+	controllerutil.RemoveFinalizer(instance, revisionImageFinalizer)
+	return r.Update(ctx, instance)
+}
+
+// createImageCleanupJob deletes image, the image of a revision, with the
+// registry credentials it was pushed with.
+func createImageCleanupJob(instance *appsv1.ControllerRevision, image string, settings *OperatorSettings) batchv1.Job {
+	namespace := instance.GetNamespace()
+	if settings.BuildNamespace != "" {
+		namespace = settings.BuildNamespace
+	}
+	ttl := int32(3600)
+	backoffLimit := int32(2)
+	return batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getImageCleanupJobName(getBuildName(instance, namespace)),
+			Namespace: namespace,
+			Labels: map[string]string{
+				accountLabel: getAccount(instance),
+			},
+			Annotations: map[string]string{
+				revisionAnnotation: instance.GetNamespace() + "/" + instance.GetName(),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			TTLSecondsAfterFinished: &ttl,
+			BackoffLimit:            &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:            "delete-image",
+						Image:           settings.Images.Crane,
+						ImagePullPolicy: "IfNotPresent",
+						Env: []corev1.EnvVar{
+							{Name: "DOCKER_CONFIG", Value: "/docker"},
+							{Name: "IMAGE", Value: image},
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "registry-credentials", MountPath: "/docker", ReadOnly: true},
+						},
+						Command: []string{"sh", "-c"},
+						Args:    []string{"crane delete \"$IMAGE\""},
+					}},
+					Volumes:       []corev1.Volume{generateRegistryCredentialsVolume(settings)},
+					RestartPolicy: "Never",
+				},
+			},
+		},
+	}
+}
+
+// findRevisionForJob enqueues the revision of an image cleanup Job.
+func (r *ReleaseRevisionReconciler) findRevisionForJob(job client.Object) []reconcile.Request {
+	namespace, name, ok := strings.Cut(job.GetAnnotations()[revisionAnnotation], "/")
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ReleaseRevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("releaserevision").
+		For(&appsv1.ControllerRevision{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return controllerutil.ContainsFinalizer(object, revisionImageFinalizer)
+		}))).
+		Watches(&source.Kind{Type: &batchv1.Job{}}, handler.EnqueueRequestsFromMapFunc(r.findRevisionForJob)).
+		Complete(r)
+}
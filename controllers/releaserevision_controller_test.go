@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+func TestPrunedRevisionDeletesItsImage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	account := &apiv1.WorkerAccount{ObjectMeta: metav1.ObjectMeta{Name: "acme", Namespace: "default"}}
+	jobBuilder := &apiv1.JobBuilder{
+		Spec:   apiv1.JobBuilderSpec{Credentials: apiv1.BuildCredentials{RegistrySecretRef: "acme-registry"}},
+		Status: apiv1.JobBuilderStatus{Image: "clementreiffers/build-acme@sha256:1234"},
+	}
+	revision, err := createReleaseRevision(account, "", jobBuilder, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&revision).Build()
+	r := &ReleaseRevisionReconciler{Client: c, Scheme: scheme, Config: DefaultOperatorConfig()}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&revision)}
+
+	if err := c.Delete(ctx, &revision); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	job := &batchv1.Job{}
+	if err := c.Get(ctx, types.NamespacedName{Name: getImageCleanupJobName(revision.Name), Namespace: "default"}, job); err != nil {
+		t.Fatalf("the image cleanup Job was not created: %v", err)
+	}
+	pod := job.Spec.Template.Spec
+	if pod.Containers[0].Env[1].Value != jobBuilder.Status.Image {
+		t.Errorf("got image %q, want %q", pod.Containers[0].Env[1].Value, jobBuilder.Status.Image)
+	}
+	if secret := pod.Volumes[0].Projected.Sources[0].Secret.Name; secret != "acme-registry" {
+		t.Errorf("got registry credentials %q, want the ones of the account", secret)
+	}
+
+	// the revision is kept until its image is deleted
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, req.NamespacedName, &appsv1.ControllerRevision{}); err != nil {
+		t.Fatalf("the revision was released before its image was deleted: %v", err)
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	if err := c.Status().Update(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Get(ctx, req.NamespacedName, &appsv1.ControllerRevision{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("the revision was not released once its image was deleted: %v", err)
+	}
+}
@@ -1,10 +1,21 @@
 package controllers
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apiv1 "operators/WorkerBundle/api/v1"
 )
 
+// accountLabel holds the account a generated resource belongs to.
+const accountLabel = "accounts"
+
+func getAccount(instance metav1.Object) string {
+	return instance.GetLabels()[accountLabel]
+}
+
 func getPodName(instance string) string {
 	return instance + "-pod"
 }
@@ -25,10 +36,50 @@ func getDeploymentName(instance string) string {
 	return instance + "-depl"
 }
 
-func getJobName(instance string) string {
-	return instance + "-job"
+// getBuildName names the build resources of a JobBuilder running in
+// namespace, suffixed with a hash of the namespace of the JobBuilder when they
+// run in a namespace shared with the JobBuilders of other namespaces.
+func getBuildName(instance metav1.Object, namespace string) string {
+	if namespace == instance.GetNamespace() {
+		return instance.GetName()
+	}
+	sum := sha256.Sum256([]byte(instance.GetNamespace()))
+	return fmt.Sprintf("%s-%x", instance.GetName(), sum[:3])
+}
+
+// getJobName names the build Job of an attempt of a JobBuilder, the Job of a
+// failed attempt is kept until its TTL for diagnostics.
+func getJobName(instance string, attempt int32) string {
+	if attempt <= 1 {
+		return instance + "-job"
+	}
+	return fmt.Sprintf("%s-job-%d", instance, attempt)
+}
+
+// getImageCleanupJobName names the Job deleting the image of a revision.
+func getImageCleanupJobName(instance string) string {
+	return instance + "-image-cleanup"
+}
+
+func getBuildConfigMapName(instance string) string {
+	return instance + "-workerd-config"
+}
+
+// getJobBuilderName names the JobBuilder of a build of the releases of an
+// account from their hash.
+func getJobBuilderName(account string, hash string) string {
+	return account + "-" + hash
 }
 
 func getWorkerRelease(instance string) string {
 	return fmt.Sprintf("worker-release-%s", instance)
 }
+
+// getEnvPrefix returns the prefix of the secret variables of a worker, derived
+// from its name when the worker does not declare one.
+func getEnvPrefix(worker apiv1.Worker) string {
+	if worker.EnvPrefix != "" {
+		return worker.EnvPrefix
+	}
+	return strings.ToUpper(strings.ReplaceAll(worker.WorkerName, "-", "_")) + "_"
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+// scriptSource fetches the scripts of a url scheme into the build context,
+// the files of a script land in /context/<scriptName>.
+type scriptSource interface {
+	// fetch returns the init containers fetching scripts and the volumes
+	// they read.
+	fetch(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) ([]v1.Container, []v1.Volume)
+}
+
+// getScriptSource returns the source of a script url from its scheme, urls
+// without a scheme are S3 object keys.
+func getScriptSource(url string) (scriptSource, error) {
+	scheme := ""
+	if i := strings.Index(url, "://"); i >= 0 {
+		scheme = url[:i]
+	}
+	switch scheme {
+	case "", "s3":
+		return s3Source{}, nil
+	case "https", "http":
+		return httpSource{}, nil
+	case "git+https", "git+http":
+		return gitSource{}, nil
+	case "configmap":
+		return configMapSource{}, nil
+	case "oci":
+		return ociSource{}, nil
+	}
+	return nil, fmt.Errorf("unsupported script url scheme %q in %s", scheme, url)
+}
+
+func getScriptContext(script apiv1.JobBuilderScript) string {
+	return "/context/" + script.ScriptName
+}
+
+func getContextVolumeMount() v1.VolumeMount {
+	return v1.VolumeMount{Name: "context", MountPath: "/context"}
+}
+
+// s3Source copies an object, or every object under a prefix, from
+// s3://bucket/key or from a key of the bucket of the operator.
+type s3Source struct{}
+
+func (s3Source) fetch(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) ([]v1.Container, []v1.Volume) {
+	var containers []v1.Container
+	for _, script := range scripts {
+		url := script.Url
+		if !strings.HasPrefix(url, "s3://") {
+			url = "s3://$(AWS_BUCKET)/" + strings.TrimPrefix(url, "/")
+		}
+		args := []string{"s3", "cp", url, getScriptContext(script), "--recursive", "--endpoint-url", "$(AWS_ENDPOINT)"}
+		if isModuleUrl(url) {
+			args = []string{"s3", "cp", url, getScriptContext(script) + "/" + getMainModule(script), "--endpoint-url", "$(AWS_ENDPOINT)"}
+		}
+		containers = append(containers, v1.Container{
+			Name:            fmt.Sprintf("fetch-s3-%d", script.Port),
+			Image:           settings.Images.AwsCli,
+			ImagePullPolicy: "IfNotPresent",
+			Env:             generateAwsConfig(settings),
+			VolumeMounts: []v1.VolumeMount{
+				getContextVolumeMount(),
+				{Name: "s3-config", MountPath: "/root/.aws", ReadOnly: true},
+			},
+			Args: args,
+		})
+	}
+	return containers, nil
+}
+
+// httpSource downloads a single file, saved as the main module of the script
+// since the url may not end with a file name.
+type httpSource struct{}
+
+func (httpSource) fetch(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) ([]v1.Container, []v1.Volume) {
+	var containers []v1.Container
+	for _, script := range scripts {
+		containers = append(containers, v1.Container{
+			Name:            fmt.Sprintf("fetch-http-%d", script.Port),
+			Image:           settings.Images.Curl,
+			ImagePullPolicy: "IfNotPresent",
+			VolumeMounts:    []v1.VolumeMount{getContextVolumeMount()},
+			Command:         []string{"curl"},
+			Args:            []string{"-fsSL", "--create-dirs", "-o", getScriptContext(script) + "/" + getMainModule(script), script.Url},
+		})
+	}
+	return containers, nil
+}
+
+// gitSource clones a repository, the url follows the docker build context
+// syntax git+https://host/repo.git#ref:subpath where ref and subpath are
+// optional.
+type gitSource struct{}
+
+func parseGitUrl(url string) (string, string, string) {
+	repository, fragment, _ := strings.Cut(strings.TrimPrefix(url, "git+"), "#")
+	ref, subpath, _ := strings.Cut(fragment, ":")
+	return repository, ref, strings.Trim(subpath, "/")
+}
+
+func (gitSource) fetch(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) ([]v1.Container, []v1.Volume) {
+	var containers []v1.Container
+	for _, script := range scripts {
+		repository, ref, subpath := parseGitUrl(script.Url)
+		clone := "git clone --depth 1"
+		if ref != "" {
+			clone += " --branch \"$GIT_REF\""
+		}
+		containers = append(containers, v1.Container{
+			Name:            fmt.Sprintf("fetch-git-%d", script.Port),
+			Image:           settings.Images.Git,
+			ImagePullPolicy: "IfNotPresent",
+			VolumeMounts:    []v1.VolumeMount{getContextVolumeMount()},
+			Env: []v1.EnvVar{
+				{Name: "GIT_REPOSITORY", Value: repository},
+				{Name: "GIT_REF", Value: ref},
+				{Name: "GIT_SUBPATH", Value: subpath},
+				{Name: "SCRIPT_CONTEXT", Value: getScriptContext(script)},
+			},
+			Command: []string{"sh", "-c"},
+			Args: []string{clone + " \"$GIT_REPOSITORY\" /tmp/repository && " +
+				"mkdir -p \"$SCRIPT_CONTEXT\" && cp -r \"/tmp/repository/$GIT_SUBPATH/.\" \"$SCRIPT_CONTEXT\" && " +
+				"rm -rf \"$SCRIPT_CONTEXT/.git\""},
+		})
+	}
+	return containers, nil
+}
+
+// configMapSource copies inline scripts from configmap://name, or from a
+// single key with configmap://name/key.
+type configMapSource struct{}
+
+func parseConfigMapUrl(url string) (string, string) {
+	name, key, _ := strings.Cut(strings.TrimPrefix(url, "configmap://"), "/")
+	return name, key
+}
+
+func (configMapSource) fetch(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) ([]v1.Container, []v1.Volume) {
+	var containers []v1.Container
+	var volumes []v1.Volume
+	for _, script := range scripts {
+		volumeName := fmt.Sprintf("script-%d", script.Port)
+		name, key := parseConfigMapUrl(script.Url)
+		source := &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: name}}
+		if key != "" {
+			source.Items = []v1.KeyToPath{{Key: key, Path: key}}
+		}
+		volumes = append(volumes, v1.Volume{Name: volumeName, VolumeSource: v1.VolumeSource{ConfigMap: source}})
+		containers = append(containers, v1.Container{
+			Name:            fmt.Sprintf("fetch-configmap-%d", script.Port),
+			Image:           settings.Images.Busybox,
+			ImagePullPolicy: "IfNotPresent",
+			VolumeMounts: []v1.VolumeMount{
+				getContextVolumeMount(),
+				{Name: volumeName, MountPath: "/source", ReadOnly: true},
+			},
+			Env:     []v1.EnvVar{{Name: "SCRIPT_CONTEXT", Value: getScriptContext(script)}},
+			Command: []string{"sh", "-c"},
+			// the files of a configmap volume are symlinks
+			Args: []string{"mkdir -p \"$SCRIPT_CONTEXT\" && cp -rL /source/. \"$SCRIPT_CONTEXT\""},
+		})
+	}
+	return containers, volumes
+}
+
+// ociSource pulls an OCI artifact with oras, oci://registry/repository:tag.
+type ociSource struct{}
+
+func (ociSource) fetch(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) ([]v1.Container, []v1.Volume) {
+	var containers []v1.Container
+	for _, script := range scripts {
+		containers = append(containers, v1.Container{
+			Name:            fmt.Sprintf("fetch-oci-%d", script.Port),
+			Image:           settings.Images.Oras,
+			ImagePullPolicy: "IfNotPresent",
+			VolumeMounts: []v1.VolumeMount{
+				getContextVolumeMount(),
+				{Name: "registry-credentials", MountPath: "/root/.docker", ReadOnly: true},
+			},
+			Args: []string{"pull", strings.TrimPrefix(script.Url, "oci://"), "--output", getScriptContext(script)},
+		})
+	}
+	return containers, nil
+}
+
+// generateSourceContainers returns the init containers and volumes fetching
+// scripts into the context, grouped by source.
+func generateSourceContainers(scripts []apiv1.JobBuilderScript, settings *OperatorSettings) ([]v1.Container, []v1.Volume, error) {
+	var sources []scriptSource
+	scriptsBySource := map[scriptSource][]apiv1.JobBuilderScript{}
+	for _, script := range scripts {
+		source, err := getScriptSource(script.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := scriptsBySource[source]; !ok {
+			sources = append(sources, source)
+		}
+		scriptsBySource[source] = append(scriptsBySource[source], script)
+	}
+
+	var containers []v1.Container
+	var volumes []v1.Volume
+	for _, source := range sources {
+		sourceContainers, sourceVolumes := source.fetch(scriptsBySource[source], settings)
+		containers = append(containers, sourceContainers...)
+		volumes = append(volumes, sourceVolumes...)
+	}
+	return containers, volumes, nil
+}
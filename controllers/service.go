@@ -3,19 +3,43 @@ package controllers
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	apiv1 "operators/WorkerBundle/api/v1"
 )
 
+// createServicePorts exposes one port per worker, targeting the container
+// port of createPodPorts.
+func createServicePorts(workers []apiv1.Worker) []corev1.ServicePort {
+	ports := make([]corev1.ServicePort, len(workers))
+	for i, worker := range workers {
+		ports[i] = corev1.ServicePort{
+			Name:       worker.WorkerName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       worker.WorkerNumber,
+			TargetPort: intstr.FromInt(int(worker.WorkerNumber)),
+		}
+	}
+	return ports
+}
+
+func isHeadless(instance *apiv1.WorkerBundle) bool {
+	return instance.Spec.ServiceType != apiv1.ServiceClusterIP
+}
+
 func createService(instance *apiv1.WorkerBundle) *corev1.Service {
+	clusterIP := ""
+	if isHeadless(instance) {
+		clusterIP = corev1.ClusterIPNone
+	}
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      getServiceName(instance.Spec.DeploymentName),
 			Namespace: instance.Namespace,
 		},
 		Spec: corev1.ServiceSpec{
-			Ports:     []corev1.ServicePort{},
+			Ports:     createServicePorts(instance.Spec.Workers),
 			Selector:  map[string]string{"app": getPodName(instance.Spec.DeploymentName)},
-			ClusterIP: "None",
+			ClusterIP: clusterIP,
 		},
 	}
 }
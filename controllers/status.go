@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "operators/WorkerBundle/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func setCondition(conditions *[]metav1.Condition, generation int64, conditionType string, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// copyCondition reports the condition of a generated resource on its parent,
+// falling back to Unknown while the child has not reported it yet.
+func copyCondition(conditions *[]metav1.Condition, generation int64, conditionType string, childConditions []metav1.Condition) {
+	child := meta.FindStatusCondition(childConditions, conditionType)
+	if child == nil {
+		setCondition(conditions, generation, conditionType, metav1.ConditionUnknown, "Pending", "")
+		return
+	}
+	setCondition(conditions, generation, conditionType, child.Status, child.Reason, child.Message)
+}
+
+// reportError records err in the Degraded and Ready conditions of instance
+// and returns it so that the request is retried.
+func reportError(ctx context.Context, c client.Client, instance client.Object, conditions *[]metav1.Condition, reason string, err error) error {
+	setCondition(conditions, instance.GetGeneration(), apiv1.ConditionDegraded, metav1.ConditionTrue, reason, err.Error())
+	setCondition(conditions, instance.GetGeneration(), apiv1.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+	statusErr := c.Status().Update(ctx, instance)
+	if statusErr != nil {
+		log.Log.WithValues("name", client.ObjectKeyFromObject(instance)).Error(statusErr, "unable to update status")
+	}
+	return err
+}
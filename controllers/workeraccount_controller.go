@@ -18,42 +18,72 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	apiv1 "operators/WorkerBundle/api/v1"
 )
 
+// releaseHashAnnotation records the hash of the WorkerReleases a JobBuilder
+// and a revision were built from.
+const releaseHashAnnotation = "api.cf-worker/release-hash"
+
+// rebuildAnnotation asks for the failed build of the releases of an account to
+// run again, a failed JobBuilder is rebuilt once the value of the account
+// differs from its own.
+const rebuildAnnotation = "api.cf-worker/rebuild"
+
 // WorkerAccountReconciler reconciles a WorkerAccount object
 type WorkerAccountReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Config *OperatorConfig
 }
 
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workeraccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workeraccounts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workeraccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
 
-func createWorkerBundle(instance *apiv1.WorkerAccount) apiv1.WorkerBundle {
+func createWorkerBundle(instance *apiv1.WorkerAccount, settings *OperatorSettings) apiv1.WorkerBundle {
 	return apiv1.WorkerBundle{
-		ObjectMeta: metav1.ObjectMeta{Name: instance.Spec.WorkerBundleName, Namespace: instance.GetNamespace()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.Spec.WorkerBundleName,
+			Namespace: instance.GetNamespace(),
+			Labels:    map[string]string{accountLabel: instance.Name},
+		},
 		Spec: apiv1.WorkerBundleSpec{
 			DeploymentName: instance.Spec.WorkerBundleName,
 			PodTemplate: apiv1.WorkerBundlePodTemplate{
 				ImagePullSecret: instance.Spec.PodTemplate.ImagePullSecret,
-				Image:           "nginx",
+				Image:           settings.Images.Placeholder,
 			},
 		},
 	}
 }
-func workerAccountApplyResource(r *WorkerAccountReconciler, ctx context.Context, resource client.Object, foundResource client.Object) error {
-	err := r.Get(ctx, types.NamespacedName{Name: resource.GetName(), Namespace: resource.GetNamespace()}, foundResource)
+func workerAccountApplyResource(r *WorkerAccountReconciler, ctx context.Context, owner client.Object, resource client.Object, foundResource client.Object) error {
+	err := ctrl.SetControllerReference(owner, resource, r.Scheme)
+	if err != nil {
+		return err
+	}
+	err = r.Get(ctx, types.NamespacedName{Name: resource.GetName(), Namespace: resource.GetNamespace()}, foundResource)
 	if err != nil && errors.IsNotFound(err) {
 		err = r.Create(ctx, resource)
 		if err != nil {
@@ -61,9 +91,144 @@ func workerAccountApplyResource(r *WorkerAccountReconciler, ctx context.Context,
 		}
 		return nil
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	// adopt resources created before owner references were set
+	if metav1.GetControllerOf(foundResource) == nil {
+		err = ctrl.SetControllerReference(owner, foundResource, r.Scheme)
+		if err != nil {
+			return err
+		}
+		return r.Update(ctx, foundResource)
+	}
+	return nil
+}
+
+// firstWorkerPort is the port of the first script of a bundle.
+const firstWorkerPort = 8080
+
+// createJobBuilderScripts pairs each script with its url, a port and the
+// bindings of its secret. Scripts keep the port they are served on by the
+// bundle, so that removing a script never moves another one, and new scripts
+// take the lowest free ports.
+func createJobBuilderScripts(releaseScripts releaseScripts, current []apiv1.Worker) []apiv1.JobBuilderScript {
+	scriptNames := make([]string, 0, len(releaseScripts.WorkerVersions))
+	for scriptName := range releaseScripts.WorkerVersions {
+		scriptNames = append(scriptNames, scriptName)
+	}
+	sort.Strings(scriptNames)
+
+	currentPorts := make(map[string]int32, len(current))
+	for _, worker := range current {
+		currentPorts[worker.WorkerName] = worker.WorkerNumber
+	}
+
+	ports := make(map[string]int32, len(scriptNames))
+	used := map[int32]bool{}
+	for _, scriptName := range scriptNames {
+		port, ok := currentPorts[scriptName]
+		if ok && port >= firstWorkerPort && !used[port] {
+			ports[scriptName] = port
+			used[port] = true
+		}
+	}
+	nextPort := int32(firstWorkerPort)
+	for _, scriptName := range scriptNames {
+		if _, ok := ports[scriptName]; ok {
+			continue
+		}
+		for used[nextPort] {
+			nextPort++
+		}
+		ports[scriptName] = nextPort
+		used[nextPort] = true
+	}
+
+	envPrefixes := make(map[string]string, len(current))
+	for _, worker := range current {
+		envPrefixes[worker.WorkerName] = worker.EnvPrefix
+	}
+
+	scripts := make([]apiv1.JobBuilderScript, 0, len(scriptNames))
+	for _, scriptName := range scriptNames {
+		// workerd reads the secret from the environment of the bundle pod
+		prefix := getEnvPrefix(apiv1.Worker{WorkerName: scriptName, EnvPrefix: envPrefixes[scriptName]})
+		var bindings []apiv1.JobBuilderBinding
+		for _, key := range releaseScripts.Bindings[scriptName] {
+			bindings = append(bindings, apiv1.JobBuilderBinding{Name: key, FromEnvironment: prefix + key})
+		}
+		scripts = append(scripts, apiv1.JobBuilderScript{
+			ScriptName:        scriptName,
+			Url:               releaseScripts.WorkerVersions[scriptName],
+			Port:              ports[scriptName],
+			Sha256:            releaseScripts.Checksums[scriptName],
+			CompatibilityDate: releaseScripts.CompatibilityDates[scriptName],
+			MainModule:        releaseScripts.MainModules[scriptName],
+			Bindings:          bindings,
+		})
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Port < scripts[j].Port
+	})
+	return scripts
+}
+
+func createJobBuilder(account *apiv1.WorkerAccount, scripts releaseScripts, hash string, currentWorkers []apiv1.Worker, settings *OperatorSettings) apiv1.JobBuilder {
+	return apiv1.JobBuilder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getJobBuilderName(account.Name, hash),
+			Namespace: account.GetNamespace(),
+			Labels:    map[string]string{accountLabel: account.Name},
+			Annotations: map[string]string{
+				releaseHashAnnotation: hash,
+				rebuildAnnotation:     account.Annotations[rebuildAnnotation],
+			},
+		},
+		Spec: apiv1.JobBuilderSpec{
+			Scripts:          createJobBuilderScripts(scripts, currentWorkers),
+			TargetImage:      settings.Registry.ImagePrefix + account.Name + ":" + hash,
+			WorkerBundleName: account.Spec.WorkerBundleName,
+			SecretRefs:       scripts.SecretRefs,
+			Credentials:      account.Spec.Credentials,
+		},
+	}
+}
+
+// getReleasesAuthor returns who asked for the releases built together, the
+// author of the first release naming one.
+func getReleasesAuthor(releases []apiv1.WorkerRelease) string {
+	for _, release := range releases {
+		if author := release.Annotations[authorAnnotation]; author != "" {
+			return author
+		}
+	}
+	return ""
+}
+
+// syncImagePullSecret propagates the pull secret of the account to its bundle.
+func (r *WorkerAccountReconciler) syncImagePullSecret(ctx context.Context, instance *apiv1.WorkerAccount, bundle *apiv1.WorkerBundle) error {
+	if bundle.Name == "" || bundle.Spec.PodTemplate.ImagePullSecret == instance.Spec.PodTemplate.ImagePullSecret {
+		return nil
+	}
+	bundle.Spec.PodTemplate.ImagePullSecret = instance.Spec.PodTemplate.ImagePullSecret
+	return r.Update(ctx, bundle)
+}
+
+// clearBundle stops serving the workers of an account left without releases.
+func (r *WorkerAccountReconciler) clearBundle(ctx context.Context, bundle *apiv1.WorkerBundle) error {
+	if bundle.Name == "" || len(bundle.Spec.Workers) == 0 && len(bundle.Spec.Scripts) == 0 {
+		return nil
+	}
+	bundle.Spec.Workers = nil
+	bundle.Spec.Scripts = nil
+	return r.Update(ctx, bundle)
 }
 
+// Reconcile creates the WorkerBundle of an account and builds the
+// WorkerReleases it selects together into the image of the bundle. The account
+// owns the JobBuilder and the revisions of the build, its releases report
+// their status.
 func (r *WorkerAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.Log.WithValues("WorkerAccount", req.NamespacedName)
 
@@ -77,21 +242,402 @@ func (r *WorkerAccountReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
-	workerBundle := createWorkerBundle(instance)
-	err = workerAccountApplyResource(r, ctx, &workerBundle, &apiv1.WorkerBundle{})
+	settings := r.Config.Resolve(instance.GetNamespace(), instance.Name)
+	workerBundle := createWorkerBundle(instance, &settings)
+	foundBundle := &apiv1.WorkerBundle{}
+	err = workerAccountApplyResource(r, ctx, instance, &workerBundle, foundBundle)
 	if err != nil {
 		logger.Error(err, "unable to create WorkerBundle")
-		return ctrl.Result{}, err
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "WorkerBundleFailed", err)
+	}
+
+	err = r.syncImagePullSecret(ctx, instance, foundBundle)
+	if err != nil {
+		logger.Error(err, "unable to update WorkerBundle")
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "WorkerBundleFailed", err)
 	}
 
 	logger.Info("successfully created a worker bundle!")
 
-	return ctrl.Result{}, nil
+	releases, err := listAccountReleases(ctx, r.Client, instance)
+	if err != nil {
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "InvalidReleaseSelector", err)
+	}
+	instance.Status.WorkerReleaseNames = nil
+	instance.Status.ReleaseGenerations = map[string]int64{}
+	for _, release := range releases {
+		instance.Status.WorkerReleaseNames = append(instance.Status.WorkerReleaseNames, release.Name)
+		instance.Status.ReleaseGenerations[release.Name] = release.Generation
+	}
+
+	err = r.buildReleases(ctx, instance, foundBundle, releases)
+	if err != nil {
+		logger.Error(err, "unable to build the releases")
+		return ctrl.Result{}, err
+	}
+
+	instance.Status.ObservedGeneration = instance.Generation
+	instance.Status.WorkerBundleName = workerBundle.Name
+	conditionTypes := []string{apiv1.ConditionProgressing, apiv1.ConditionReady}
+	// a failed build takes precedence over the state of the bundle
+	if !meta.IsStatusConditionTrue(instance.Status.Conditions, apiv1.ConditionDegraded) {
+		conditionTypes = append(conditionTypes, apiv1.ConditionDegraded)
+	}
+	for _, conditionType := range conditionTypes {
+		copyCondition(&instance.Status.Conditions, instance.Generation, conditionType, foundBundle.Status.Conditions)
+	}
+	return ctrl.Result{}, r.Status().Update(ctx, instance)
+}
+
+// buildReleases builds the releases of an account into the image of its
+// bundle, or hands their scripts over to a bundle in Mount mode. It reports the
+// build in the Building and Degraded conditions of the account.
+func (r *WorkerAccountReconciler) buildReleases(ctx context.Context, instance *apiv1.WorkerAccount, bundle *apiv1.WorkerBundle, releases []apiv1.WorkerRelease) error {
+	logger := log.Log.WithValues("WorkerAccount", client.ObjectKeyFromObject(instance))
+
+	if len(releases) == 0 {
+		err := r.deleteJobBuilders(ctx, instance, "")
+		if err != nil {
+			return err
+		}
+		err = r.clearBundle(ctx, bundle)
+		if err != nil {
+			return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "WorkerBundleFailed", err)
+		}
+		instance.Status.JobBuilderName = ""
+		instance.Status.Image = ""
+		instance.Status.Revision = 0
+		setBuildConditions(instance, "NoReleases")
+		return nil
+	}
+
+	scripts := mergeReleases(releases)
+	r.loadBindings(ctx, instance.GetNamespace(), &scripts)
+	hash := getReleasesHash(scripts)
+	jobBuilderName := getJobBuilderName(instance.Name, hash)
+
+	if bundle.Spec.Mode == apiv1.ModeMount {
+		// the pods of the bundle fetch the scripts themselves, nothing is built
+		err := r.deleteJobBuilders(ctx, instance, "")
+		if err != nil {
+			return err
+		}
+		return r.mountScripts(ctx, instance, bundle, scripts)
+	}
+
+	rollbackTo, err := getRollbackTo(releases)
+	if err != nil {
+		return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "ConflictingRollback", err)
+	}
+	if rollbackTo != 0 {
+		// a build finishing after the rollback would overwrite the bundle
+		err = r.deleteJobBuilders(ctx, instance, "")
+		if err != nil {
+			return err
+		}
+		return r.rollback(ctx, instance, rollbackTo)
+	}
+
+	err = r.deleteJobBuilders(ctx, instance, jobBuilderName)
+	if err != nil {
+		return err
+	}
+
+	revision, err := findReleaseRevision(ctx, r.Client, instance, hash)
+	if err != nil {
+		return err
+	}
+	if revision != nil {
+		// the releases were already built, serve that build again
+		return r.serveRevision(ctx, instance, revision, scripts.SecretRefs, "Released")
+	}
+
+	jobBuilder := apiv1.JobBuilder{}
+	err = r.Get(ctx, types.NamespacedName{Name: jobBuilderName, Namespace: instance.GetNamespace()}, &jobBuilder)
+	if err == nil {
+		switch {
+		case !jobBuilder.DeletionTimestamp.IsZero():
+			// the JobBuilder is created again once the failed one is gone
+			setRebuildConditions(instance, &jobBuilder)
+			return nil
+		case jobBuilder.Status.Phase == apiv1.JobBuilderSucceeded:
+			revision, err = r.recordRevision(ctx, instance, releases, &jobBuilder)
+			if err != nil {
+				return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "RevisionFailed", err)
+			}
+			instance.Status.JobBuilderName = jobBuilder.Name
+			return r.serveRevision(ctx, instance, revision, jobBuilder.Spec.SecretRefs, "Released")
+		case jobBuilder.Status.Phase == apiv1.JobBuilderFailed && jobBuilder.Annotations[rebuildAnnotation] != instance.Annotations[rebuildAnnotation]:
+			err = r.Delete(ctx, &jobBuilder, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			logger.Info("failed JobBuilder deleted to be rebuilt", "JobBuilder", jobBuilder.Name)
+			setRebuildConditions(instance, &jobBuilder)
+			return nil
+		}
+		reportBuild(instance, &jobBuilder)
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	settings := r.Config.Resolve(instance.GetNamespace(), instance.Name)
+	jobBuilder = createJobBuilder(instance, scripts, hash, bundle.Spec.Workers, &settings)
+	err = ctrl.SetControllerReference(instance, &jobBuilder, r.Scheme)
+	if err != nil {
+		return err
+	}
+	err = r.Create(ctx, &jobBuilder)
+	if err != nil {
+		logger.Error(err, "unable to create a JobBuilder")
+		return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "JobBuilderFailed", err)
+	}
+	logger.Info("JobBuilder created!")
+	reportBuild(instance, &jobBuilder)
+	return nil
+}
+
+// setBuildConditions reports that the releases of an account are served.
+func setBuildConditions(instance *apiv1.WorkerAccount, reason string) {
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionFalse, reason, "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionDegraded, metav1.ConditionFalse, reason, "")
+}
+
+// setRebuildConditions reports that a failed build of the releases of an
+// account is about to run again.
+func setRebuildConditions(instance *apiv1.WorkerAccount, jobBuilder *apiv1.JobBuilder) {
+	instance.Status.JobBuilderName = jobBuilder.Name
+	instance.Status.Image = ""
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionBuilding, metav1.ConditionTrue, "Rebuilding", "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionDegraded, metav1.ConditionFalse, "Rebuilding", "")
+}
+
+// reportBuild reports the progress of the JobBuilder building the releases of
+// an account.
+func reportBuild(instance *apiv1.WorkerAccount, jobBuilder *apiv1.JobBuilder) {
+	instance.Status.JobBuilderName = jobBuilder.Name
+	instance.Status.Image = jobBuilder.Status.Image
+	for _, conditionType := range []string{apiv1.ConditionBuilding, apiv1.ConditionDegraded} {
+		copyCondition(&instance.Status.Conditions, instance.Generation, conditionType, jobBuilder.Status.Conditions)
+	}
+}
+
+// loadBindings reads the keys of the secret of each script, a missing secret
+// binds nothing until it is created.
+func (r *WorkerAccountReconciler) loadBindings(ctx context.Context, namespace string, scripts *releaseScripts) {
+	for scriptName, secretRef := range scripts.SecretRefs {
+		secret := v1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: secretRef, Namespace: namespace}, &secret)
+		if err != nil {
+			log.Log.WithValues("Secret", secretRef).Info("unable to read the bindings of a script", "script", scriptName, "error", err.Error())
+			continue
+		}
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		scripts.Bindings[scriptName] = keys
+	}
+}
+
+// recordRevision records a successful build of the releases of an account as a
+// new ControllerRevision and prunes the revisions beyond the history limit.
+func (r *WorkerAccountReconciler) recordRevision(ctx context.Context, instance *apiv1.WorkerAccount, releases []apiv1.WorkerRelease, jobBuilder *apiv1.JobBuilder) (*appsv1.ControllerRevision, error) {
+	revisions, err := listReleaseRevisions(ctx, r.Client, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := instance.Status.Revision
+	for _, revision := range revisions {
+		if revision.Revision > latest {
+			latest = revision.Revision
+		}
+	}
+	revision, err := createReleaseRevision(instance, getReleasesAuthor(releases), jobBuilder, mergeReleases(releases).WorkerVersions, latest+1)
+	if err != nil {
+		return nil, err
+	}
+	err = ctrl.SetControllerReference(instance, &revision, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	err = r.Create(ctx, &revision)
+	if errors.IsAlreadyExists(err) {
+		// the revision recorded by a previous reconcile is not in the cache yet
+		found := appsv1.ControllerRevision{}
+		err = r.Get(ctx, client.ObjectKeyFromObject(&revision), &found)
+		if err != nil {
+			return nil, err
+		}
+		if found.Annotations[releaseHashAnnotation] != revision.Annotations[releaseHashAnnotation] {
+			return nil, fmt.Errorf("the revision %s records another build", found.Name)
+		}
+		return &found, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Log.WithValues("WorkerAccount", client.ObjectKeyFromObject(instance)).Info("revision recorded", "revision", revision.Revision)
+
+	limit, err := getReleaseHistoryLimit(ctx, r.Client, releases)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, pruneReleaseRevisions(ctx, r.Client, append(revisions, revision), revision.Revision, limit)
+}
+
+// rollback points the WorkerBundle of an account at the image of its revision
+// rollbackTo.
+func (r *WorkerAccountReconciler) rollback(ctx context.Context, instance *apiv1.WorkerAccount, rollbackTo int64) error {
+	revisions, err := listReleaseRevisions(ctx, r.Client, instance)
+	if err != nil {
+		return err
+	}
+	for i := range revisions {
+		if revisions[i].Revision != rollbackTo {
+			continue
+		}
+		content, err := decodeReleaseRevision(&revisions[i])
+		if err != nil {
+			return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "RevisionInvalid", err)
+		}
+		return r.serveRevision(ctx, instance, &revisions[i], content.SecretRefs, "RolledBack")
+	}
+	return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "RevisionNotFound",
+		fmt.Errorf("the account %s has no revision %d", instance.Name, rollbackTo))
+}
+
+// serveRevision points the WorkerBundle of an account at the image of a
+// revision, exposing secretRefs to its scripts.
+func (r *WorkerAccountReconciler) serveRevision(ctx context.Context, instance *apiv1.WorkerAccount, revision *appsv1.ControllerRevision, secretRefs map[string]string, reason string) error {
+	content, err := decodeReleaseRevision(revision)
+	if err != nil {
+		return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "RevisionInvalid", err)
+	}
+
+	err = updateBundleImage(ctx, r.Client, instance.GetNamespace(), instance.Spec.WorkerBundleName, content.Image, content.Scripts, secretRefs)
+	if err != nil {
+		return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "BundleUpdateFailed", err)
+	}
+	if instance.Status.Revision != revision.Revision {
+		log.Log.WithValues("WorkerAccount", client.ObjectKeyFromObject(instance)).Info("serving revision", "revision", revision.Revision, "reason", reason)
+	}
+
+	instance.Status.Revision = revision.Revision
+	instance.Status.Image = content.Image
+	setBuildConditions(instance, reason)
+	return nil
+}
+
+// mountScripts hands the scripts of the releases of an account over to its
+// WorkerBundle in Mount mode, its pods fetch them when they start.
+func (r *WorkerAccountReconciler) mountScripts(ctx context.Context, instance *apiv1.WorkerAccount, bundle *apiv1.WorkerBundle, releaseScripts releaseScripts) error {
+	scripts := createJobBuilderScripts(releaseScripts, bundle.Spec.Workers)
+	workers := generateWorkers(scripts, releaseScripts.SecretRefs, bundle.Spec.Workers)
+	if !equality.Semantic.DeepEqual(bundle.Spec.Scripts, scripts) || !equalWorkers(bundle.Spec.Workers, workers) {
+		bundle.Spec.Scripts = scripts
+		bundle.Spec.Workers = workers
+		err := r.Update(ctx, bundle)
+		if err != nil {
+			return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "BundleUpdateFailed", err)
+		}
+		log.Log.WithValues("WorkerAccount", client.ObjectKeyFromObject(instance)).Info("scripts mounted", "WorkerBundle", bundle.Name)
+	}
+
+	instance.Status.JobBuilderName = ""
+	instance.Status.Image = ""
+	setBuildConditions(instance, "Mounted")
+	return nil
+}
+
+// isAccountJobBuilder reports whether a JobBuilder was created for the
+// releases of account, JobBuilders created before they belonged to the
+// account are controlled by one of its releases.
+func isAccountJobBuilder(jobBuilder *apiv1.JobBuilder, account *apiv1.WorkerAccount) bool {
+	owner := metav1.GetControllerOf(jobBuilder)
+	if owner == nil {
+		return false
+	}
+	return owner.UID == account.UID || owner.APIVersion == apiv1.GroupVersion.String() && owner.Kind == "WorkerRelease"
+}
+
+// deleteJobBuilders garbage-collects the JobBuilders of the account but the
+// one named keep.
+func (r *WorkerAccountReconciler) deleteJobBuilders(ctx context.Context, account *apiv1.WorkerAccount, keep string) error {
+	jobBuilders := &apiv1.JobBuilderList{}
+	err := r.List(ctx, jobBuilders, client.InNamespace(account.GetNamespace()), client.MatchingLabels{accountLabel: account.Name})
+	if err != nil {
+		return err
+	}
+	for i := range jobBuilders.Items {
+		jobBuilder := &jobBuilders.Items[i]
+		if jobBuilder.Name == keep || !isAccountJobBuilder(jobBuilder, account) || !jobBuilder.DeletionTimestamp.IsZero() {
+			continue
+		}
+		err = r.Delete(ctx, jobBuilder, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.Log.WithValues("WorkerAccount", client.ObjectKeyFromObject(account)).Info("JobBuilder deleted", "JobBuilder", jobBuilder.Name)
+	}
+	return nil
+}
+
+// findAccountsForRelease enqueues the accounts selecting a release.
+func (r *WorkerAccountReconciler) findAccountsForRelease(release client.Object) []reconcile.Request {
+	accounts, err := listReleaseAccounts(context.Background(), r.Client, release)
+	if err != nil {
+		log.Log.Error(err, "unable to list the accounts of a release", "WorkerRelease", client.ObjectKeyFromObject(release))
+		return nil
+	}
+	requests := make([]reconcile.Request, len(accounts))
+	for i, account := range accounts {
+		requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&account)}
+	}
+	return requests
+}
+
+// findAccountsForSecret enqueues the accounts selecting the releases binding
+// secret, its keys are compiled into the workerd configuration.
+func (r *WorkerAccountReconciler) findAccountsForSecret(secret client.Object) []reconcile.Request {
+	releases := &apiv1.WorkerReleaseList{}
+	err := r.List(context.Background(), releases, client.InNamespace(secret.GetNamespace()))
+	if err != nil {
+		log.Log.Error(err, "unable to list the releases of a secret", "Secret", client.ObjectKeyFromObject(secret))
+		return nil
+	}
+	seen := map[types.NamespacedName]bool{}
+	var requests []reconcile.Request
+	for i := range releases.Items {
+		release := &releases.Items[i]
+		binds := false
+		for _, secretRef := range release.Spec.SecretRefs {
+			binds = binds || secretRef == secret.GetName()
+		}
+		if !binds {
+			continue
+		}
+		for _, request := range r.findAccountsForRelease(release) {
+			if !seen[request.NamespacedName] {
+				seen[request.NamespacedName] = true
+				requests = append(requests, request)
+			}
+		}
+	}
+	return requests
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkerAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&apiv1.WorkerAccount{}).
+		Owns(&apiv1.WorkerBundle{}).
+		Owns(&apiv1.JobBuilder{}).
+		Watches(&source.Kind{Type: &apiv1.WorkerRelease{}}, handler.EnqueueRequestsFromMapFunc(r.findAccountsForRelease)).
+		Watches(&source.Kind{Type: &v1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.findAccountsForSecret)).
 		Complete(r)
 }
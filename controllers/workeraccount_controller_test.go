@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+func TestCreateJobBuilderScripts(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions map[string]string
+		current  []apiv1.Worker
+		want     map[string]int32
+	}{
+		{
+			name:     "new scripts are served by name order",
+			versions: map[string]string{"b": "b.js", "a": "a.js", "c": "c.js"},
+			want:     map[string]int32{"a": 8080, "b": 8081, "c": 8082},
+		},
+		{
+			name:     "scripts keep their port",
+			versions: map[string]string{"a": "a.js", "b": "b.js"},
+			current:  []apiv1.Worker{{WorkerName: "a", WorkerNumber: 8081}, {WorkerName: "b", WorkerNumber: 8080}},
+			want:     map[string]int32{"a": 8081, "b": 8080},
+		},
+		{
+			name:     "an added script takes the first free port",
+			versions: map[string]string{"a": "a.js", "b": "b.js", "c": "c.js"},
+			current:  []apiv1.Worker{{WorkerName: "b", WorkerNumber: 8080}, {WorkerName: "c", WorkerNumber: 8082}},
+			want:     map[string]int32{"a": 8081, "b": 8080, "c": 8082},
+		},
+		{
+			name:     "removing a middle script keeps the other ports",
+			versions: map[string]string{"a": "a.js", "c": "c.js"},
+			current:  []apiv1.Worker{{WorkerName: "a", WorkerNumber: 8080}, {WorkerName: "b", WorkerNumber: 8081}, {WorkerName: "c", WorkerNumber: 8082}},
+			want:     map[string]int32{"a": 8080, "c": 8082},
+		},
+		{
+			name:     "a script added after a removal takes the port left free",
+			versions: map[string]string{"a": "a.js", "c": "c.js", "d": "d.js", "e": "e.js"},
+			current:  []apiv1.Worker{{WorkerName: "a", WorkerNumber: 8080}, {WorkerName: "c", WorkerNumber: 8082}},
+			want:     map[string]int32{"a": 8080, "c": 8082, "d": 8081, "e": 8083},
+		},
+		{
+			name:     "scripts sharing a port are split",
+			versions: map[string]string{"a": "a.js", "b": "b.js"},
+			current:  []apiv1.Worker{{WorkerName: "a", WorkerNumber: 8080}, {WorkerName: "b", WorkerNumber: 8080}},
+			want:     map[string]int32{"a": 8080, "b": 8081},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scripts := createJobBuilderScripts(releaseScripts{WorkerVersions: test.versions}, test.current)
+			ports := map[string]int32{}
+			for i, script := range scripts {
+				ports[script.ScriptName] = script.Port
+				if script.Url != test.versions[script.ScriptName] {
+					t.Errorf("script %s has url %s, want %s", script.ScriptName, script.Url, test.versions[script.ScriptName])
+				}
+				if i > 0 && scripts[i-1].Port >= script.Port {
+					t.Errorf("scripts are not sorted by port: %v", scripts)
+				}
+			}
+			if !reflect.DeepEqual(ports, test.want) {
+				t.Errorf("got ports %v, want %v", ports, test.want)
+			}
+		})
+	}
+}
+
+func TestCreateJobBuilderScriptsBindings(t *testing.T) {
+	scripts := createJobBuilderScripts(releaseScripts{
+		WorkerVersions: map[string]string{"my-script": "worker.js", "other": "other.js"},
+		Bindings:       map[string][]string{"my-script": {"API_KEY", "TOKEN"}, "other": {"API_KEY"}},
+	}, []apiv1.Worker{{WorkerName: "other", WorkerNumber: 8081, EnvPrefix: "CUSTOM_"}})
+
+	want := map[string][]apiv1.JobBuilderBinding{
+		"my-script": {{Name: "API_KEY", FromEnvironment: "MY_SCRIPT_API_KEY"}, {Name: "TOKEN", FromEnvironment: "MY_SCRIPT_TOKEN"}},
+		"other":     {{Name: "API_KEY", FromEnvironment: "CUSTOM_API_KEY"}},
+	}
+	for _, script := range scripts {
+		if !reflect.DeepEqual(script.Bindings, want[script.ScriptName]) {
+			t.Errorf("script %s has bindings %v, want %v", script.ScriptName, script.Bindings, want[script.ScriptName])
+		}
+	}
+}
+
+func TestBuildReleasesRebuildsFailedBuild(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	account := &apiv1.WorkerAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "acme", Namespace: "default", UID: types.UID("acme")},
+		Spec:       apiv1.WorkerAccountSpec{WorkerBundleName: "acme"},
+	}
+	releases := []apiv1.WorkerRelease{{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-release-acme", Namespace: "default", Labels: map[string]string{accountLabel: "acme"}},
+		Spec:       apiv1.WorkerReleaseSpec{Accounts: "acme", WorkerVersions: map[string]string{"hello": "s3://bucket/hello"}},
+	}}
+	bundle := &apiv1.WorkerBundle{ObjectMeta: metav1.ObjectMeta{Name: "acme", Namespace: "default"}}
+	settings := DefaultOperatorConfig().Resolve("default", "acme")
+	failed := createJobBuilder(account, mergeReleases(releases), getReleasesHash(mergeReleases(releases)), nil, &settings)
+	if err := ctrl.SetControllerReference(account, &failed, scheme); err != nil {
+		t.Fatal(err)
+	}
+	failed.Status.Phase = apiv1.JobBuilderFailed
+	failed.Status.Conditions = []metav1.Condition{{Type: apiv1.ConditionDegraded, Status: metav1.ConditionTrue, Reason: "BuildFailed"}}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(account, &releases[0], bundle, &failed).Build()
+	r := &WorkerAccountReconciler{Client: c, Scheme: scheme, Config: DefaultOperatorConfig()}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(&failed)
+
+	if err := r.buildReleases(ctx, account, bundle, releases); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(account.Status.Conditions, apiv1.ConditionDegraded) {
+		t.Errorf("the failed build is not reported: %v", account.Status.Conditions)
+	}
+	if err := c.Get(ctx, key, &apiv1.JobBuilder{}); err != nil {
+		t.Fatalf("the failed JobBuilder was deleted without a rebuild: %v", err)
+	}
+
+	account.Annotations = map[string]string{rebuildAnnotation: "1"}
+	if err := r.buildReleases(ctx, account, bundle, releases); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, key, &apiv1.JobBuilder{}); !errors.IsNotFound(err) {
+		t.Fatalf("the failed JobBuilder was not deleted: %v", err)
+	}
+	if condition := meta.FindStatusCondition(account.Status.Conditions, apiv1.ConditionBuilding); condition == nil || condition.Reason != "Rebuilding" {
+		t.Errorf("the rebuild is not reported: %v", account.Status.Conditions)
+	}
+
+	if err := r.buildReleases(ctx, account, bundle, releases); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := &apiv1.JobBuilder{}
+	if err := c.Get(ctx, key, rebuilt); err != nil {
+		t.Fatalf("the JobBuilder was not created again: %v", err)
+	}
+	if rebuilt.Status.Phase == apiv1.JobBuilderFailed || rebuilt.Annotations[rebuildAnnotation] != "1" {
+		t.Errorf("got phase %q and rebuild %q, want a new build for rebuild 1", rebuilt.Status.Phase, rebuilt.Annotations[rebuildAnnotation])
+	}
+}
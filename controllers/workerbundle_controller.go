@@ -18,40 +18,104 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	apiv1 "operators/WorkerBundle/api/v1"
 )
 
+// secretsHashAnnotation holds a hash of the secrets referenced by the workers
+// of a bundle on its pod template.
+const secretsHashAnnotation = "api.cf-worker/secrets-hash"
+
+// configHashAnnotation holds a hash of the workerd configuration of a bundle in
+// Mount mode on its pod template.
+const configHashAnnotation = "api.cf-worker/config-hash"
+
+// scriptsHashAnnotation holds a hash of the ConfigMaps holding the scripts of
+// a bundle in Mount mode on its pod template.
+const scriptsHashAnnotation = "api.cf-worker/scripts-hash"
+
+// workerSecretIndex indexes WorkerBundles by the secrets their workers reference.
+const workerSecretIndex = ".spec.workers.secretRef"
+
+// scriptConfigMapIndex indexes WorkerBundles by the ConfigMaps their scripts
+// are read from.
+const scriptConfigMapIndex = ".spec.scripts.configMap"
+
 // WorkerBundleReconciler reconciles a WorkerBundle object
 type WorkerBundleReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Config *OperatorConfig
 }
 
+// workerBundleFieldOwner is the field manager used to server-side apply the
+// Deployment, Service and Ingress of a WorkerBundle.
+const workerBundleFieldOwner = "workerbundle-controller"
+
+// workerBundleLegacyManagers are the field managers of children created before
+// they were server-side applied, client-go names them after the binary.
+var workerBundleLegacyManagers = sets.New[string](filepath.Base(os.Args[0]), "manager")
+
 func workerBundleApplyResource(r *WorkerBundleReconciler, ctx context.Context, resource client.Object, foundResource client.Object) error {
-	err := r.Get(ctx, types.NamespacedName{Name: resource.GetName(), Namespace: resource.GetNamespace()}, foundResource)
-	if err != nil && errors.IsNotFound(err) {
-		err = r.Create(ctx, resource)
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil {
+		return err
+	}
+	resource.GetObjectKind().SetGroupVersionKind(gvk)
+
+	err = r.Get(ctx, types.NamespacedName{Name: resource.GetName(), Namespace: resource.GetNamespace()}, foundResource)
+	if err == nil {
+		// hand the fields set by the old Create call over to the apply manager,
+		// otherwise ports and paths of dropped workers would never be pruned
+		patch, err := csaupgrade.UpgradeManagedFieldsPatch(foundResource, workerBundleLegacyManagers, workerBundleFieldOwner)
 		if err != nil {
 			return err
 		}
-		return nil
+		if patch != nil {
+			err = r.Patch(ctx, foundResource, client.RawPatch(types.JSONPatchType, patch))
+			if err != nil {
+				return err
+			}
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
 	}
-	return err
+
+	return r.Patch(ctx, resource, client.Apply, client.FieldOwner(workerBundleFieldOwner), client.ForceOwnership)
 }
 
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workerbundles,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workerbundles/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workerbundles/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=api.cf-worker,resources=workeraccounts,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -76,41 +140,338 @@ func (r *WorkerBundleReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, err
 	}
 
-	workers := instance.Spec.Workers
-
-	if len(workers) == 0 {
-		logger.Info("no workers defined")
-		return ctrl.Result{}, nil
+	secretsHash, err := r.hashSecrets(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "SecretFailed", err)
 	}
 
-	depl := createDeployment(instance)
+	settings, err := r.resolveSettings(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	podSpec := createPodSpec(instance)
+	annotations := map[string]string{secretsHashAnnotation: secretsHash}
+	var configMap *corev1.ConfigMap
+	if instance.Spec.Mode == apiv1.ModeMount {
+		podSpec, err = createMountPodSpec(instance, &settings)
+		if err != nil {
+			logger.Error(err, "unable to generate the pods fetching the scripts")
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "InvalidScriptUrl", err)
+		}
+		configMap = createMountConfigMap(instance)
+		config := sha256.Sum256([]byte(configMap.Data["config.capnp"]))
+		annotations[configHashAnnotation] = hex.EncodeToString(config[:])
+		// the pods copy the scripts of the ConfigMaps when they start
+		annotations[scriptsHashAnnotation], err = r.hashScriptConfigMaps(ctx, instance)
+		if err != nil {
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "ConfigMapFailed", err)
+		}
+	}
+	depl := createDeployment(instance, podSpec, annotations)
 	svc := createService(instance)
-	ing := createIngress(instance)
+	ing := createIngress(instance, &settings)
+	if len(instance.Spec.Workers) == 0 {
+		// nothing is served, the pods are scaled down and the Service and
+		// Ingress, which cannot be empty, are removed
+		logger.Info("no workers defined")
+		replicas := int32(0)
+		depl.Spec.Replicas = &replicas
+		svc, ing = nil, nil
+	}
+	resources := []client.Object{&depl}
+	if svc != nil {
+		resources = append(resources, svc, ing)
+	}
+	if configMap != nil {
+		resources = append(resources, configMap)
+	}
+	for _, resource := range resources {
+		err = ctrl.SetControllerReference(instance, resource, r.Scheme)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
+	if configMap != nil {
+		err = workerBundleApplyResource(r, ctx, configMap, &corev1.ConfigMap{})
+		if err != nil {
+			logger.Error(err, "unable to apply the workerd configuration")
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "ConfigMapFailed", err)
+		}
+	}
 	err = workerBundleApplyResource(r, ctx, &depl, &appsv1.Deployment{})
 	if err != nil {
-		logger.Error(err, "unable to create Deployment")
-		return ctrl.Result{}, err
+		logger.Error(err, "unable to apply Deployment")
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "DeploymentFailed", err)
+	}
+	if svc == nil {
+		err = r.deleteRoutes(ctx, instance)
+		if err != nil {
+			logger.Error(err, "unable to delete the Service and Ingress")
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "ServiceFailed", err)
+		}
+		return ctrl.Result{}, r.updateStatus(ctx, instance, &depl, &settings)
+	}
+	err = r.replaceServiceOnTypeChange(ctx, svc)
+	if err != nil {
+		logger.Error(err, "unable to replace Service")
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "ServiceFailed", err)
 	}
 	err = workerBundleApplyResource(r, ctx, svc, &corev1.Service{})
 	if err != nil {
-		logger.Error(err, "unable to create Service")
-		return ctrl.Result{}, err
+		logger.Error(err, "unable to apply Service")
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "ServiceFailed", err)
 	}
 	err = workerBundleApplyResource(r, ctx, ing, &networkingv1.Ingress{})
 	if err != nil {
-		logger.Error(err, "unable to create Ingress")
-		return ctrl.Result{}, err
+		logger.Error(err, "unable to apply Ingress")
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "IngressFailed", err)
 	}
 
-	logger.Info("successfully created a deployment!")
+	logger.Info("successfully applied the deployment!")
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{}, r.updateStatus(ctx, instance, &depl, &settings)
+}
+
+// resolveSettings returns the operator settings of a bundle, with the
+// credentials of its account that the pods of a bundle in Mount mode read.
+func (r *WorkerBundleReconciler) resolveSettings(ctx context.Context, instance *apiv1.WorkerBundle) (OperatorSettings, error) {
+	settings := r.Config.Resolve(instance.GetNamespace(), getAccount(instance))
+	if getAccount(instance) == "" {
+		return settings, nil
+	}
+	account := &apiv1.WorkerAccount{}
+	err := r.Get(ctx, types.NamespacedName{Name: getAccount(instance), Namespace: instance.GetNamespace()}, account)
+	if err != nil {
+		return settings, client.IgnoreNotFound(err)
+	}
+	applyCredentials(&settings, account.Spec.Credentials)
+	return settings, nil
+}
+
+// findBundleForAccount enqueues the bundle of an account, its pods read the
+// credentials of the account in Mount mode.
+func (r *WorkerBundleReconciler) findBundleForAccount(account client.Object) []reconcile.Request {
+	bundleName := account.(*apiv1.WorkerAccount).Spec.WorkerBundleName
+	if bundleName == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: bundleName, Namespace: account.GetNamespace()}}}
+}
+
+// replaceServiceOnTypeChange deletes the Service when switching between headless
+// and ClusterIP, since the cluster IP of a Service cannot be changed in place.
+func (r *WorkerBundleReconciler) replaceServiceOnTypeChange(ctx context.Context, svc *corev1.Service) error {
+	found := &corev1.Service{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(svc), found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	wantHeadless := svc.Spec.ClusterIP == corev1.ClusterIPNone
+	if (found.Spec.ClusterIP == corev1.ClusterIPNone) == wantHeadless {
+		return nil
+	}
+	err = r.Delete(ctx, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// deleteRoutes deletes the Service and the Ingress of a bundle left without
+// workers.
+func (r *WorkerBundleReconciler) deleteRoutes(ctx context.Context, instance *apiv1.WorkerBundle) error {
+	routes := []client.Object{
+		&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: getIngressName(instance.Spec.DeploymentName), Namespace: instance.GetNamespace()}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: getServiceName(instance.Spec.DeploymentName), Namespace: instance.GetNamespace()}},
+	}
+	for _, route := range routes {
+		err := r.Delete(ctx, route)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getWorkerSecrets(instance *apiv1.WorkerBundle) []string {
+	secrets := sets.New[string]()
+	for _, worker := range instance.Spec.Workers {
+		if worker.SecretRef != "" {
+			secrets.Insert(worker.SecretRef)
+		}
+	}
+	return sets.List(secrets)
+}
+
+// hashSecrets hashes the content of the secrets referenced by the workers, a
+// missing secret is hashed as such so that its creation restarts the pods.
+func (r *WorkerBundleReconciler) hashSecrets(ctx context.Context, instance *apiv1.WorkerBundle) (string, error) {
+	hash := sha256.New()
+	for _, name := range getWorkerSecrets(instance) {
+		hash.Write([]byte(name + "\x00"))
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.GetNamespace()}, secret)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			hash.Write([]byte(key + "\x00"))
+			hash.Write(secret.Data[key])
+			hash.Write([]byte("\x00"))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func getScriptConfigMaps(instance *apiv1.WorkerBundle) []string {
+	configMaps := sets.New[string]()
+	for _, script := range instance.Spec.Scripts {
+		if strings.HasPrefix(script.Url, "configmap://") {
+			name, _ := parseConfigMapUrl(script.Url)
+			configMaps.Insert(name)
+		}
+	}
+	return sets.List(configMaps)
+}
+
+// hashScriptConfigMaps hashes the content of the ConfigMaps the scripts are
+// read from, a missing ConfigMap is hashed as such so that its creation
+// restarts the pods.
+func (r *WorkerBundleReconciler) hashScriptConfigMaps(ctx context.Context, instance *apiv1.WorkerBundle) (string, error) {
+	hash := sha256.New()
+	for _, name := range getScriptConfigMaps(instance) {
+		hash.Write([]byte(name + "\x00"))
+		configMap := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.GetNamespace()}, configMap)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+		}
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			hash.Write([]byte(key + "\x00"))
+			hash.Write(data[key])
+			hash.Write([]byte("\x00"))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// findBundlesForConfigMap enqueues the bundles whose scripts are read from
+// configMap.
+func (r *WorkerBundleReconciler) findBundlesForConfigMap(configMap client.Object) []reconcile.Request {
+	bundles := &apiv1.WorkerBundleList{}
+	err := r.List(context.Background(), bundles,
+		client.InNamespace(configMap.GetNamespace()),
+		client.MatchingFields{scriptConfigMapIndex: configMap.GetName()})
+	if err != nil {
+		log.Log.Error(err, "unable to list the bundles of a ConfigMap", "ConfigMap", client.ObjectKeyFromObject(configMap))
+		return nil
+	}
+	requests := make([]reconcile.Request, len(bundles.Items))
+	for i, bundle := range bundles.Items {
+		requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&bundle)}
+	}
+	return requests
+}
+
+// findBundlesForSecret enqueues the bundles whose workers reference secret.
+func (r *WorkerBundleReconciler) findBundlesForSecret(secret client.Object) []reconcile.Request {
+	bundles := &apiv1.WorkerBundleList{}
+	err := r.List(context.Background(), bundles,
+		client.InNamespace(secret.GetNamespace()),
+		client.MatchingFields{workerSecretIndex: secret.GetName()})
+	if err != nil {
+		log.Log.Error(err, "unable to list the bundles of a secret", "Secret", client.ObjectKeyFromObject(secret))
+		return nil
+	}
+	requests := make([]reconcile.Request, len(bundles.Items))
+	for i, bundle := range bundles.Items {
+		requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&bundle)}
+	}
+	return requests
+}
+
+func isDeploymentRolledOut(depl *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if depl.Spec.Replicas != nil {
+		replicas = *depl.Spec.Replicas
+	}
+	return depl.Status.ObservedGeneration >= depl.Generation &&
+		depl.Status.UpdatedReplicas == replicas &&
+		depl.Status.AvailableReplicas == replicas &&
+		depl.Status.Replicas == replicas
+}
+
+func (r *WorkerBundleReconciler) updateStatus(ctx context.Context, instance *apiv1.WorkerBundle, depl *appsv1.Deployment, settings *OperatorSettings) error {
+	instance.Status.ObservedGeneration = instance.Generation
+	instance.Status.Image = depl.Spec.Template.Spec.Containers[0].Image
+	instance.Status.AvailableReplicas = depl.Status.AvailableReplicas
+	instance.Status.Urls = getWorkerUrls(instance, settings)
+
+	conditions := &instance.Status.Conditions
+	setCondition(conditions, instance.Generation, apiv1.ConditionDegraded, metav1.ConditionFalse, "Applied", "")
+	if len(instance.Spec.Workers) == 0 {
+		setCondition(conditions, instance.Generation, apiv1.ConditionProgressing, metav1.ConditionFalse, "NoWorkers", "")
+		setCondition(conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, "NoWorkers", "no workers defined")
+	} else if isDeploymentRolledOut(depl) {
+		setCondition(conditions, instance.Generation, apiv1.ConditionProgressing, metav1.ConditionFalse, "RolloutComplete", "")
+		setCondition(conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionTrue, "DeploymentAvailable", "")
+	} else {
+		setCondition(conditions, instance.Generation, apiv1.ConditionProgressing, metav1.ConditionTrue, "RollingOut", "waiting for the deployment to roll out")
+		setCondition(conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, "DeploymentUnavailable", "")
+	}
+	return r.Status().Update(ctx, instance)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkerBundleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &apiv1.WorkerBundle{}, workerSecretIndex, func(obj client.Object) []string {
+		return getWorkerSecrets(obj.(*apiv1.WorkerBundle))
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &apiv1.WorkerBundle{}, scriptConfigMapIndex, func(obj client.Object) []string {
+		return getScriptConfigMaps(obj.(*apiv1.WorkerBundle))
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&apiv1.WorkerBundle{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.findBundlesForSecret)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.findBundlesForConfigMap)).
+		Watches(&source.Kind{Type: &apiv1.WorkerAccount{}}, handler.EnqueueRequestsFromMapFunc(r.findBundleForAccount)).
 		Complete(r)
 }
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+func TestHashScriptConfigMaps(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	bundle := &apiv1.WorkerBundle{
+		ObjectMeta: metav1.ObjectMeta{Name: "acme", Namespace: "default"},
+		Spec: apiv1.WorkerBundleSpec{
+			Mode: apiv1.ModeMount,
+			Scripts: []apiv1.JobBuilderScript{
+				{ScriptName: "hello", Url: "configmap://hello/index.js", Port: 8080},
+				{ScriptName: "world", Url: "s3://bucket/world", Port: 8081},
+			},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Data:       map[string]string{"index.js": "export default {}"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(configMap).Build()
+	r := &WorkerBundleReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+
+	before, err := r.hashScriptConfigMaps(ctx, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMap.Data["index.js"] = "export default { fetch() {} }"
+	if err := c.Update(ctx, configMap); err != nil {
+		t.Fatal(err)
+	}
+	after, err := r.hashScriptConfigMaps(ctx, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == after {
+		t.Error("editing the ConfigMap of a script does not change the hash of the pod template")
+	}
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+const (
+	// defaultCompatibilityDate is used for the scripts that do not set one.
+	defaultCompatibilityDate = "2023-02-28"
+	// defaultMainModule is the entry point of the scripts fetched as a
+	// directory that do not set one.
+	defaultMainModule = "worker.js"
+)
+
+// moduleTypes are the workerd module types by file extension.
+var moduleTypes = map[string]string{
+	".js":   "esModule",
+	".mjs":  "esModule",
+	".cjs":  "commonJsModule",
+	".wasm": "wasm",
+}
+
+// isModuleUrl reports whether url points to a single module rather than to a
+// directory of files.
+func isModuleUrl(url string) bool {
+	_, ok := moduleTypes[path.Ext(url)]
+	return ok
+}
+
+// getMainModule returns the path of the entry point of a script within its
+// directory of the build context.
+func getMainModule(script apiv1.JobBuilderScript) string {
+	if script.MainModule != "" {
+		return script.MainModule
+	}
+	if isModuleUrl(script.Url) {
+		return path.Base(script.Url)
+	}
+	return defaultMainModule
+}
+
+func getModuleType(module string) string {
+	if moduleType, ok := moduleTypes[path.Ext(module)]; ok {
+		return moduleType
+	}
+	return "esModule"
+}
+
+// renderWorkerdConfig renders the workerd configuration serving each script on
+// its port, the modules are embedded from the directories of the build context.
+func renderWorkerdConfig(scripts []apiv1.JobBuilderScript) string {
+	var config strings.Builder
+	config.WriteString("using Workerd = import \"/workerd/workerd.capnp\";\n\n")
+
+	config.WriteString("const config :Workerd.Config = (\n  services = [\n")
+	for _, script := range scripts {
+		fmt.Fprintf(&config, "    (name = %s, worker = .worker%d),\n", strconv.Quote(script.ScriptName), script.Port)
+	}
+	config.WriteString("  ],\n  sockets = [\n")
+	for _, script := range scripts {
+		fmt.Fprintf(&config, "    (name = %s, address = \"*:%d\", http = (), service = %s),\n",
+			strconv.Quote(script.ScriptName), script.Port, strconv.Quote(script.ScriptName))
+	}
+	config.WriteString("  ],\n);\n")
+
+	for _, script := range scripts {
+		mainModule := getMainModule(script)
+		compatibilityDate := script.CompatibilityDate
+		if compatibilityDate == "" {
+			compatibilityDate = defaultCompatibilityDate
+		}
+		fmt.Fprintf(&config, "\nconst worker%d :Workerd.Worker = (\n", script.Port)
+		fmt.Fprintf(&config, "  modules = [\n    (name = %s, %s = embed %s),\n  ],\n",
+			strconv.Quote(mainModule), getModuleType(mainModule), strconv.Quote(script.ScriptName+"/"+mainModule))
+		fmt.Fprintf(&config, "  compatibilityDate = %s,\n", strconv.Quote(compatibilityDate))
+		if len(script.Bindings) > 0 {
+			config.WriteString("  bindings = [\n")
+			for _, binding := range script.Bindings {
+				fmt.Fprintf(&config, "    (name = %s, fromEnvironment = %s),\n", strconv.Quote(binding.Name), strconv.Quote(binding.FromEnvironment))
+			}
+			config.WriteString("  ],\n")
+		}
+		config.WriteString(");\n")
+	}
+	return config.String()
+}
+
+// renderDockerfile renders the Dockerfile compiling the workerd configuration
+// of the build context into a single binary.
+func renderDockerfile(settings *OperatorSettings) string {
+	return fmt.Sprintf(`FROM %s AS builder
+
+COPY ./ ./
+
+RUN workerd compile config.capnp > serv.out
+
+FROM %s AS runner
+
+COPY --from=builder serv.out .
+
+CMD ["./serv.out"]
+`, settings.Images.WorkerdBuilder, settings.Images.WorkerdRunner)
+}
+
+// createBuildConfigMap holds the workerd configuration and the Dockerfile of
+// a build, they are copied into the build context next to the scripts.
+func createBuildConfigMap(instance *apiv1.JobBuilder, settings *OperatorSettings) v1.ConfigMap {
+	return v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getBuildConfigMapName(getBuildName(instance, getBuildNamespace(instance, settings))),
+			Namespace: instance.GetNamespace(),
+		},
+		Data: map[string]string{
+			"config.capnp": renderWorkerdConfig(instance.Spec.Scripts),
+			"Dockerfile":   renderDockerfile(settings),
+		},
+	}
+}
+
+// createMountConfigMap holds the workerd configuration of a bundle in Mount
+// mode, it is copied next to the scripts fetched by its pods.
+func createMountConfigMap(instance *apiv1.WorkerBundle) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getBuildConfigMapName(instance.Spec.DeploymentName),
+			Namespace: instance.GetNamespace(),
+		},
+		Data: map[string]string{
+			"config.capnp": renderWorkerdConfig(instance.Spec.Scripts),
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"testing"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+func TestRenderWorkerdConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		scripts []apiv1.JobBuilderScript
+		want    string
+	}{
+		{
+			name:    "no scripts",
+			scripts: nil,
+			want: `using Workerd = import "/workerd/workerd.capnp";
+
+const config :Workerd.Config = (
+  services = [
+  ],
+  sockets = [
+  ],
+);
+`,
+		},
+		{
+			name: "directory and single file scripts",
+			scripts: []apiv1.JobBuilderScript{
+				{ScriptName: "hello", Url: "s3://bucket/hello", Port: 8080},
+				{
+					ScriptName:        "api",
+					Url:               "https://example.com/api.mjs",
+					Port:              8081,
+					CompatibilityDate: "2023-05-01",
+					Bindings:          []apiv1.JobBuilderBinding{{Name: "TOKEN", FromEnvironment: "API_TOKEN"}},
+				},
+				{ScriptName: "wasm", Url: "git+https://example.com/wasm.git", Port: 8082, MainModule: "main.wasm"},
+			},
+			want: `using Workerd = import "/workerd/workerd.capnp";
+
+const config :Workerd.Config = (
+  services = [
+    (name = "hello", worker = .worker8080),
+    (name = "api", worker = .worker8081),
+    (name = "wasm", worker = .worker8082),
+  ],
+  sockets = [
+    (name = "hello", address = "*:8080", http = (), service = "hello"),
+    (name = "api", address = "*:8081", http = (), service = "api"),
+    (name = "wasm", address = "*:8082", http = (), service = "wasm"),
+  ],
+);
+
+const worker8080 :Workerd.Worker = (
+  modules = [
+    (name = "worker.js", esModule = embed "hello/worker.js"),
+  ],
+  compatibilityDate = "2023-02-28",
+);
+
+const worker8081 :Workerd.Worker = (
+  modules = [
+    (name = "api.mjs", esModule = embed "api/api.mjs"),
+  ],
+  compatibilityDate = "2023-05-01",
+  bindings = [
+    (name = "TOKEN", fromEnvironment = "API_TOKEN"),
+  ],
+);
+
+const worker8082 :Workerd.Worker = (
+  modules = [
+    (name = "main.wasm", wasm = embed "wasm/main.wasm"),
+  ],
+  compatibilityDate = "2023-02-28",
+);
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := renderWorkerdConfig(test.scripts); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetMainModule(t *testing.T) {
+	tests := []struct {
+		script apiv1.JobBuilderScript
+		want   string
+	}{
+		{script: apiv1.JobBuilderScript{Url: "s3://bucket/dir"}, want: "worker.js"},
+		{script: apiv1.JobBuilderScript{Url: "https://example.com/index.mjs"}, want: "index.mjs"},
+		{script: apiv1.JobBuilderScript{Url: "https://example.com/worker?version=2"}, want: "worker.js"},
+		{script: apiv1.JobBuilderScript{Url: "https://example.com/index.mjs", MainModule: "src/index.mjs"}, want: "src/index.mjs"},
+	}
+	for _, test := range tests {
+		t.Run(test.script.Url, func(t *testing.T) {
+			if got := getMainModule(test.script); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
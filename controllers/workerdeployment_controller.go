@@ -18,15 +18,27 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	apiv1 "operators/WorkerBundle/api/v1"
 )
 
+// workerDeploymentAccountIndex indexes the WorkerDeployments by account so that
+// the releases and bundles of an account can find their deployments.
+const workerDeploymentAccountIndex = ".spec.accounts"
+
 // WorkerDeploymentReconciler reconciles a WorkerDeployment object
 type WorkerDeploymentReconciler struct {
 	client.Client
@@ -37,26 +49,243 @@ type WorkerDeploymentReconciler struct {
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workerdeployments/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workerdeployments/finalizers,verbs=update
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the WorkerDeployment object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
+// getScriptUrl returns the url of the script of a template, read from the
+// former scriptsUrls field of the deployments stored before it was renamed.
+func getScriptUrl(template *apiv1.WorkerDeploymentTemplate) (string, error) {
+	urls := template.ScriptUrls
+	if len(urls) == 0 {
+		urls = template.ScriptsUrls
+	}
+	if len(urls) != 1 {
+		return "", fmt.Errorf("the template must list a single url in scriptUrls, found %d", len(urls))
+	}
+	return urls[0], nil
+}
+
+func createWorkerVersion(instance *apiv1.WorkerDeployment, url string) apiv1.WorkerVersion {
+	return apiv1.WorkerVersion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        instance.GetName(),
+			Namespace:   instance.GetNamespace(),
+			Labels:      map[string]string{accountLabel: instance.Spec.Accounts},
+			Annotations: map[string]string{authorAnnotation: instance.Annotations[authorAnnotation]},
+		},
+		Spec: apiv1.WorkerVersionSpec{
+			Accounts:          instance.Spec.Accounts,
+			Scripts:           instance.Spec.Template.ScriptName,
+			Url:               url,
+			SecretRef:         instance.Spec.Template.SecretRef,
+			CompatibilityDate: instance.Spec.Template.CompatibilityDate,
+			MainModule:        instance.Spec.Template.MainModule,
+			Sha256:            instance.Spec.Template.Sha256,
+		},
+	}
+}
+
+// Reconcile keeps the WorkerVersion of the deployed script in sync with the
+// template; the WorkerVersion, WorkerRelease and WorkerAccount controllers
+// then build the script and roll it out on the WorkerBundle of the account.
 func (r *WorkerDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := log.Log.WithValues("WorkerDeployment", req.NamespacedName)
+
+	instance := &apiv1.WorkerDeployment{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if !instance.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	url, err := getScriptUrl(&instance.Spec.Template)
+	if err != nil {
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "InvalidTemplate", err)
+	}
+	workerVersion := createWorkerVersion(instance, url)
+	foundVersion := apiv1.WorkerVersion{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(&workerVersion), &foundVersion)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		err = ctrl.SetControllerReference(instance, &workerVersion, r.Scheme)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		err = r.Create(ctx, &workerVersion)
+		if err != nil {
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "WorkerVersionFailed", err)
+		}
+		logger.Info("WorkerVersion created!")
+	} else if foundVersion.Spec.Scripts != workerVersion.Spec.Scripts || foundVersion.Spec.Accounts != workerVersion.Spec.Accounts {
+		// the finalizer of the version only removes its own script from its
+		// own release, so a renamed script needs a new version
+		if foundVersion.DeletionTimestamp.IsZero() {
+			err = r.Delete(ctx, &foundVersion)
+			if err != nil && !errors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+			logger.Info("WorkerVersion replaced")
+		}
+		return ctrl.Result{Requeue: true}, nil
+	} else if foundVersion.Spec != workerVersion.Spec || foundVersion.Annotations[authorAnnotation] != instance.Annotations[authorAnnotation] {
+		foundVersion.Spec = workerVersion.Spec
+		if foundVersion.Annotations == nil {
+			foundVersion.Annotations = map[string]string{}
+		}
+		foundVersion.Annotations[authorAnnotation] = instance.Annotations[authorAnnotation]
+		err = r.Update(ctx, &foundVersion)
+		if err != nil {
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "WorkerVersionFailed", err)
+		}
+		logger.Info("WorkerVersion updated!")
+	}
+
+	return ctrl.Result{}, r.updateStatus(ctx, instance, &workerVersion)
+}
+
+// updateStatus reports the progress of the script through its release and
+// the bundle of its account.
+func (r *WorkerDeploymentReconciler) updateStatus(ctx context.Context, instance *apiv1.WorkerDeployment, workerVersion *apiv1.WorkerVersion) error {
+	instance.Status.ObservedGeneration = instance.Generation
+	instance.Status.WorkerVersionName = workerVersion.Name
+	instance.Status.WorkerReleaseName = getWorkerRelease(instance.Spec.Accounts)
+
+	progressing := func(reason string) error {
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionProgressing, metav1.ConditionTrue, reason, "")
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, reason, "")
+		return r.Status().Update(ctx, instance)
+	}
 
-	// TODO(user): your logic here
+	workerRelease := apiv1.WorkerRelease{}
+	err := r.Get(ctx, types.NamespacedName{Name: instance.Status.WorkerReleaseName, Namespace: instance.GetNamespace()}, &workerRelease)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return progressing("WaitingForRelease")
+		}
+		return err
+	}
+	if workerRelease.Spec.WorkerVersions[workerVersion.Spec.Scripts] != workerVersion.Spec.Url ||
+		workerRelease.Status.ObservedGeneration < workerRelease.Generation {
+		return progressing("WaitingForRelease")
+	}
+	// the revisions of the builds are recorded by the release
+	instance.Status.Revision = workerRelease.Status.Revision
+	copyCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionDegraded, workerRelease.Status.Conditions)
+	if meta.IsStatusConditionTrue(workerRelease.Status.Conditions, apiv1.ConditionDegraded) {
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionProgressing, metav1.ConditionFalse, "BuildFailed", "")
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, "BuildFailed", "")
+		return r.Status().Update(ctx, instance)
+	}
+	if !meta.IsStatusConditionTrue(workerRelease.Status.Conditions, apiv1.ConditionReady) {
+		return progressing("Building")
+	}
 
-	return ctrl.Result{}, nil
+	// the accounts select their releases by label, spec.accounts is not the
+	// name of the account
+	accounts, err := listReleaseAccounts(ctx, r.Client, &workerRelease)
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return reportError(ctx, r.Client, instance, &instance.Status.Conditions, "AccountNotFound",
+			fmt.Errorf("no WorkerAccount selects the WorkerRelease %s", workerRelease.Name))
+	}
+	workerAccount := accounts[0]
+	instance.Status.WorkerBundleName = workerAccount.Spec.WorkerBundleName
+
+	bundle := apiv1.WorkerBundle{}
+	err = r.Get(ctx, types.NamespacedName{Name: workerAccount.Spec.WorkerBundleName, Namespace: instance.GetNamespace()}, &bundle)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return progressing("WaitingForBundle")
+		}
+		return err
+	}
+	copyCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionDegraded, bundle.Status.Conditions)
+	if bundle.Status.ObservedGeneration < bundle.Generation ||
+		!meta.IsStatusConditionTrue(bundle.Status.Conditions, apiv1.ConditionReady) {
+		return progressing("RollingOut")
+	}
+
+	instance.Status.Image = bundle.Status.Image
+	instance.Status.Url = ""
+	for _, workerUrl := range bundle.Status.Urls {
+		if workerUrl.WorkerName == instance.Spec.Template.ScriptName {
+			instance.Status.Url = workerUrl.Url
+		}
+	}
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionProgressing, metav1.ConditionFalse, "RolledOut", "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionTrue, "RolledOut", "")
+	return r.Status().Update(ctx, instance)
+}
+
+// indexDeploymentAccount indexes a WorkerDeployment by its spec.accounts.
+func indexDeploymentAccount(obj client.Object) []string {
+	return []string{obj.(*apiv1.WorkerDeployment).Spec.Accounts}
+}
+
+// listAccountDeployments returns the deployments whose spec.accounts is
+// accounts.
+func (r *WorkerDeploymentReconciler) listAccountDeployments(ctx context.Context, namespace string, accounts string) ([]apiv1.WorkerDeployment, error) {
+	deployments := &apiv1.WorkerDeploymentList{}
+	err := r.List(ctx, deployments,
+		client.InNamespace(namespace),
+		client.MatchingFields{workerDeploymentAccountIndex: accounts})
+	return deployments.Items, err
+}
+
+// findDeploymentsForRelease enqueues the deployments of the scripts of a
+// release.
+func (r *WorkerDeploymentReconciler) findDeploymentsForRelease(obj client.Object) []reconcile.Request {
+	release := obj.(*apiv1.WorkerRelease)
+	deployments, err := r.listAccountDeployments(context.Background(), release.GetNamespace(), release.Spec.Accounts)
+	if err != nil {
+		log.Log.Error(err, "unable to list the deployments of a release", "WorkerRelease", client.ObjectKeyFromObject(release))
+		return nil
+	}
+	requests := make([]reconcile.Request, len(deployments))
+	for i, deployment := range deployments {
+		requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&deployment)}
+	}
+	return requests
+}
+
+// findDeploymentsForBundle enqueues the deployments of the releases selected
+// by the account of a bundle.
+func (r *WorkerDeploymentReconciler) findDeploymentsForBundle(bundle client.Object) []reconcile.Request {
+	ctx := context.Background()
+	account := &apiv1.WorkerAccount{}
+	err := r.Get(ctx, types.NamespacedName{Name: getAccount(bundle), Namespace: bundle.GetNamespace()}, account)
+	if err != nil {
+		return nil
+	}
+	releases, err := listAccountReleases(ctx, r.Client, account)
+	if err != nil {
+		log.Log.Error(err, "unable to list the releases of an account", "WorkerAccount", client.ObjectKeyFromObject(account))
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range releases {
+		requests = append(requests, r.findDeploymentsForRelease(&releases[i])...)
+	}
+	return requests
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkerDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &apiv1.WorkerDeployment{}, workerDeploymentAccountIndex, indexDeploymentAccount)
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&apiv1.WorkerDeployment{}).
+		Owns(&apiv1.WorkerVersion{}).
+		Watches(&source.Kind{Type: &apiv1.WorkerRelease{}}, handler.EnqueueRequestsFromMapFunc(r.findDeploymentsForRelease)).
+		Watches(&source.Kind{Type: &apiv1.WorkerBundle{}}, handler.EnqueueRequestsFromMapFunc(r.findDeploymentsForBundle)).
 		Complete(r)
 }
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "operators/WorkerBundle/api/v1"
+)
+
+func TestGetScriptUrl(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  apiv1.WorkerDeploymentTemplate
+		want      string
+		wantError bool
+	}{
+		{name: "scriptUrls", template: apiv1.WorkerDeploymentTemplate{ScriptUrls: []string{"s3://bucket/a"}}, want: "s3://bucket/a"},
+		{name: "former scriptsUrls", template: apiv1.WorkerDeploymentTemplate{ScriptsUrls: []string{"s3://bucket/a"}}, want: "s3://bucket/a"},
+		{
+			name:     "scriptUrls first",
+			template: apiv1.WorkerDeploymentTemplate{ScriptUrls: []string{"s3://bucket/a"}, ScriptsUrls: []string{"s3://bucket/b"}},
+			want:     "s3://bucket/a",
+		},
+		{name: "no url", wantError: true},
+		{name: "several urls", template: apiv1.WorkerDeploymentTemplate{ScriptsUrls: []string{"s3://bucket/a", "s3://bucket/b"}}, wantError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := getScriptUrl(&test.template)
+			if (err != nil) != test.wantError {
+				t.Fatalf("got error %v, want error %t", err, test.wantError)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// newSampleAccount returns the objects of config/samples: the account is not
+// named after the accounts value of its releases, it selects them by label.
+func newSampleAccount() (*apiv1.WorkerDeployment, *apiv1.WorkerRelease, *apiv1.WorkerAccount, *apiv1.WorkerBundle) {
+	ready := []metav1.Condition{{Type: apiv1.ConditionReady, Status: metav1.ConditionTrue, Reason: "Released"}}
+	deployment := &apiv1.WorkerDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "wasm-worker", Namespace: "default"},
+		Spec: apiv1.WorkerDeploymentSpec{
+			Accounts: "1234",
+			Template: apiv1.WorkerDeploymentTemplate{ScriptName: "wasm-worker", ScriptUrls: []string{"s3://bucket/wasm-worker"}},
+		},
+	}
+	release := &apiv1.WorkerRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: getWorkerRelease("1234"), Namespace: "default", Labels: map[string]string{accountLabel: "1234"}},
+		Spec:       apiv1.WorkerReleaseSpec{Accounts: "1234", WorkerVersions: map[string]string{"wasm-worker": "s3://bucket/wasm-worker"}},
+		Status:     apiv1.WorkerReleaseStatus{Conditions: ready},
+	}
+	account := &apiv1.WorkerAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "398803b74bcdb1b454434669bc634190", Namespace: "default"},
+		Spec: apiv1.WorkerAccountSpec{
+			WorkerBundleName:      "worker-bundle-name",
+			WorkerReleaseSelector: metav1.LabelSelector{MatchLabels: map[string]string{accountLabel: "1234"}},
+		},
+	}
+	bundle := &apiv1.WorkerBundle{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-bundle-name", Namespace: "default", Labels: map[string]string{accountLabel: account.Name}},
+		Status: apiv1.WorkerBundleStatus{
+			Conditions: ready,
+			Urls:       []apiv1.WorkerUrl{{WorkerName: "wasm-worker", Url: "http://wasm-worker.example.com"}},
+		},
+	}
+	return deployment, release, account, bundle
+}
+
+func newWorkerDeploymentReconciler(t *testing.T) (*WorkerDeploymentReconciler, *apiv1.WorkerDeployment) {
+	scheme := runtime.NewScheme()
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	deployment, release, account, bundle := newSampleAccount()
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(deployment, release, account, bundle).
+		WithIndex(&apiv1.WorkerDeployment{}, workerDeploymentAccountIndex, indexDeploymentAccount).
+		Build()
+	return &WorkerDeploymentReconciler{Client: c, Scheme: scheme}, deployment
+}
+
+func TestUpdateStatusResolvesSelectingAccount(t *testing.T) {
+	r, deployment := newWorkerDeploymentReconciler(t)
+	workerVersion := createWorkerVersion(deployment, deployment.Spec.Template.ScriptUrls[0])
+
+	err := r.updateStatus(context.Background(), deployment, &workerVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Status.WorkerBundleName != "worker-bundle-name" {
+		t.Errorf("got bundle %q, want worker-bundle-name", deployment.Status.WorkerBundleName)
+	}
+	if !meta.IsStatusConditionTrue(deployment.Status.Conditions, apiv1.ConditionReady) {
+		t.Errorf("deployment not ready: %v", deployment.Status.Conditions)
+	}
+	if deployment.Status.Url != "http://wasm-worker.example.com" {
+		t.Errorf("got url %q, want http://wasm-worker.example.com", deployment.Status.Url)
+	}
+}
+
+func TestFindDeploymentsForBundle(t *testing.T) {
+	r, deployment := newWorkerDeploymentReconciler(t)
+	_, _, _, bundle := newSampleAccount()
+
+	requests := r.findDeploymentsForBundle(bundle)
+	if len(requests) != 1 || requests[0].Name != deployment.Name {
+		t.Errorf("got requests %v, want the deployment %s", requests, deployment.Name)
+	}
+}
@@ -19,14 +19,17 @@ package controllers
 import (
 	"context"
 	"fmt"
+
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	apiv1 "operators/WorkerBundle/api/v1"
 )
@@ -41,38 +44,10 @@ type WorkerReleaseReconciler struct {
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workerreleases/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=api.cf-worker,resources=workerreleases/finalizers,verbs=update
 
-func getAllScriptsUrls(instance *apiv1.WorkerRelease) []string {
-	values := make([]string, 0, len(instance.Spec.WorkerVersions))
-	for _, value := range instance.Spec.WorkerVersions {
-		values = append(values, value)
-	}
-	return values
-}
-
-func getAllScriptNames(instance *apiv1.WorkerRelease) []string {
-	keys := make([]string, 0, len(instance.Spec.WorkerVersions))
-	for key := range instance.Spec.WorkerVersions {
-		keys = append(keys, key)
-	}
-	return keys
-}
-
-func createJobBuilder(instance *apiv1.WorkerRelease, bundleName string) apiv1.JobBuilder {
-	return apiv1.JobBuilder{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      instance.Spec.Accounts,
-			Namespace: instance.GetNamespace()},
-		Spec: apiv1.JobBuilderSpec{
-			ScriptUrls:       getAllScriptsUrls(instance),
-			TargetImage:      fmt.Sprintf("clementreiffers/build-%s", instance.Spec.Accounts),
-			WorkerBundleName: bundleName,
-			ScriptNames:      getAllScriptNames(instance),
-		},
-	}
-}
-
+// Reconcile reports the build of the WorkerAccount selecting a WorkerRelease,
+// the account builds all of its releases together.
 func (r *WorkerReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := log.Log.WithValues("JobBuilder", req.NamespacedName)
+	logger := log.Log.WithValues("WorkerRelease", req.NamespacedName)
 
 	instance := &apiv1.WorkerRelease{}
 	err := r.Get(ctx, req.NamespacedName, instance)
@@ -82,46 +57,71 @@ func (r *WorkerReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		}
 		return ctrl.Result{}, err
 	}
+	if !instance.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
 
-	workerAccount := apiv1.WorkerAccount{}
-	err = r.Get(ctx, types.NamespacedName{Name: instance.Spec.Accounts, Namespace: instance.GetNamespace()}, &workerAccount)
+	accounts, err := listReleaseAccounts(ctx, r.Client, instance)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	if len(accounts) == 0 {
+		return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "AccountNotFound",
+			fmt.Errorf("no WorkerAccount selects the WorkerRelease %s", instance.Name))
+	}
+	if len(accounts) > 1 {
+		logger.Info("WorkerRelease selected by several accounts, only the first one is reported", "WorkerAccount", accounts[0].Name)
+	}
+	return ctrl.Result{}, r.updateStatus(ctx, instance, &accounts[0])
+}
+
+// updateStatus reports the build of account once it was made from the current
+// generation of the release.
+func (r *WorkerReleaseReconciler) updateStatus(ctx context.Context, instance *apiv1.WorkerRelease, account *apiv1.WorkerAccount) error {
+	generation, ok := account.Status.ReleaseGenerations[instance.Name]
+	if !ok || generation < instance.Generation {
+		// the account reports the release once it built it
+		return nil
+	}
 
-	bundleName := workerAccount.Spec.WorkerBundleName
+	instance.Status.ObservedGeneration = generation
+	instance.Status.JobBuilderName = account.Status.JobBuilderName
+	instance.Status.Image = account.Status.Image
+	instance.Status.Revision = account.Status.Revision
+	for _, conditionType := range []string{apiv1.ConditionBuilding, apiv1.ConditionDegraded} {
+		copyCondition(&instance.Status.Conditions, instance.Generation, conditionType, account.Status.Conditions)
+	}
+	building := meta.FindStatusCondition(account.Status.Conditions, apiv1.ConditionBuilding)
+	degraded := meta.FindStatusCondition(account.Status.Conditions, apiv1.ConditionDegraded)
+	switch {
+	case degraded != nil && degraded.Status == metav1.ConditionTrue:
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, degraded.Reason, degraded.Message)
+	case building == nil || building.Status != metav1.ConditionFalse:
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionFalse, "Building", "")
+	default:
+		setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionTrue, building.Reason, "")
+	}
+	return r.Status().Update(ctx, instance)
+}
 
-	jobBuilder := apiv1.JobBuilder{}
-	err = r.Get(ctx, types.NamespacedName{Name: instance.Spec.Accounts, Namespace: instance.GetNamespace()}, &jobBuilder)
+// findReleasesForAccount enqueues the releases selected by an account.
+func (r *WorkerReleaseReconciler) findReleasesForAccount(account client.Object) []reconcile.Request {
+	releases, err := listAccountReleases(context.Background(), r.Client, account.(*apiv1.WorkerAccount))
 	if err != nil {
-		jobBuilder := createJobBuilder(instance, bundleName)
-		err = r.Create(ctx, &jobBuilder)
-		if err != nil {
-			logger.Error(err, "unable to create a JobBuilder")
-			return ctrl.Result{}, err
-		}
-		logger.Info("JobBuilder created!")
-		return ctrl.Result{}, nil
-	} else {
-		err = r.Delete(ctx, &jobBuilder)
-		if err != nil {
-			logger.Error(err, "unable to destroy the job builder")
-			return ctrl.Result{}, err
-		}
-		jobBuilder := createJobBuilder(instance, bundleName)
-		err = r.Create(ctx, &jobBuilder)
-		if err != nil {
-			logger.Error(err, "unable to create a JobBuilder")
-			return ctrl.Result{}, err
-		}
-		logger.Info("JobBuilder created!")
-		return ctrl.Result{}, nil
+		log.Log.Error(err, "unable to list the releases of an account", "WorkerAccount", client.ObjectKeyFromObject(account))
+		return nil
+	}
+	requests := make([]reconcile.Request, len(releases))
+	for i, release := range releases {
+		requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&release)}
 	}
+	return requests
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkerReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&apiv1.WorkerRelease{}).
+		Watches(&source.Kind{Type: &apiv1.WorkerAccount{}}, handler.EnqueueRequestsFromMapFunc(r.findReleasesForAccount)).
 		Complete(r)
 }
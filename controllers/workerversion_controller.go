@@ -25,11 +25,16 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	apiv1 "operators/WorkerBundle/api/v1"
 )
 
+// workerVersionFinalizer lets a WorkerVersion take its script out of the shared
+// WorkerRelease before it goes away.
+const workerVersionFinalizer = "api.cf-worker/worker-version"
+
 // WorkerVersionReconciler reconciles a WorkerVersion object
 type WorkerVersionReconciler struct {
 	client.Client
@@ -52,16 +57,39 @@ type WorkerVersionReconciler struct {
 
 func createWorkerRelease(instance *apiv1.WorkerVersion) apiv1.WorkerRelease {
 	return apiv1.WorkerRelease{
-		ObjectMeta: metav1.ObjectMeta{Name: getWorkerRelease(instance.Spec.Accounts), Namespace: instance.GetNamespace()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        getWorkerRelease(instance.Spec.Accounts),
+			Namespace:   instance.GetNamespace(),
+			Labels:      map[string]string{accountLabel: instance.Spec.Accounts},
+			Annotations: map[string]string{authorAnnotation: instance.Annotations[authorAnnotation]},
+		},
 		Spec: apiv1.WorkerReleaseSpec{
 			WorkerVersions: map[string]string{
 				instance.Spec.Scripts: instance.Spec.Url,
 			},
-			Accounts: instance.Spec.Accounts,
+			Accounts:           instance.Spec.Accounts,
+			SecretRefs:         setScriptValue(nil, instance.Spec.Scripts, instance.Spec.SecretRef),
+			Checksums:          setScriptValue(nil, instance.Spec.Scripts, instance.Spec.Sha256),
+			CompatibilityDates: setScriptValue(nil, instance.Spec.Scripts, instance.Spec.CompatibilityDate),
+			MainModules:        setScriptValue(nil, instance.Spec.Scripts, instance.Spec.MainModule),
 		},
 	}
 }
 
+// setScriptValue records the value of a script in values, an empty value
+// removes the script.
+func setScriptValue(values map[string]string, script string, value string) map[string]string {
+	if value == "" {
+		delete(values, script)
+		return values
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+	values[script] = value
+	return values
+}
+
 func (r *WorkerVersionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.Log.WithValues("WorkerVersion", req.NamespacedName)
 
@@ -75,27 +103,115 @@ func (r *WorkerVersionReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
+	if !instance.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, instance)
+	}
+
+	if !controllerutil.ContainsFinalizer(instance, workerVersionFinalizer) {
+		controllerutil.AddFinalizer(instance, workerVersionFinalizer)
+		err = r.Update(ctx, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	workerRelease := apiv1.WorkerRelease{}
 	err = r.Get(ctx, types.NamespacedName{Name: getWorkerRelease(instance.Spec.Accounts), Namespace: instance.GetNamespace()}, &workerRelease)
 	if err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
 		workerRelease := createWorkerRelease(instance)
-		err = r.Create(ctx, &workerRelease)
+		err = controllerutil.SetOwnerReference(instance, &workerRelease, r.Scheme)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+		err = r.Create(ctx, &workerRelease)
+		if err != nil {
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "WorkerReleaseFailed", err)
+		}
 		logger.Info("WorkerRelease created!")
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, r.updateStatus(ctx, instance, &workerRelease)
 
 	} else {
+		if workerRelease.Spec.WorkerVersions == nil {
+			workerRelease.Spec.WorkerVersions = map[string]string{}
+		}
 		workerRelease.Spec.WorkerVersions[instance.Spec.Scripts] = instance.Spec.Url
-		err = r.Update(ctx, &workerRelease)
+		workerRelease.Spec.SecretRefs = setScriptValue(workerRelease.Spec.SecretRefs, instance.Spec.Scripts, instance.Spec.SecretRef)
+		workerRelease.Spec.Checksums = setScriptValue(workerRelease.Spec.Checksums, instance.Spec.Scripts, instance.Spec.Sha256)
+		workerRelease.Spec.CompatibilityDates = setScriptValue(workerRelease.Spec.CompatibilityDates, instance.Spec.Scripts, instance.Spec.CompatibilityDate)
+		workerRelease.Spec.MainModules = setScriptValue(workerRelease.Spec.MainModules, instance.Spec.Scripts, instance.Spec.MainModule)
+		// the revision built next is credited to the last author of the release
+		if workerRelease.Annotations == nil {
+			workerRelease.Annotations = map[string]string{}
+		}
+		workerRelease.Annotations[authorAnnotation] = instance.Annotations[authorAnnotation]
+		if getAccount(&workerRelease) == "" {
+			if workerRelease.Labels == nil {
+				workerRelease.Labels = map[string]string{}
+			}
+			workerRelease.Labels[accountLabel] = instance.Spec.Accounts
+		}
+		err = controllerutil.SetOwnerReference(instance, &workerRelease, r.Scheme)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+		err = r.Update(ctx, &workerRelease)
+		if err != nil {
+			return ctrl.Result{}, reportError(ctx, r.Client, instance, &instance.Status.Conditions, "WorkerReleaseFailed", err)
+		}
 		logger.Info("WorkerRelease updated!")
+		return ctrl.Result{}, r.updateStatus(ctx, instance, &workerRelease)
+	}
+
+}
+
+func (r *WorkerVersionReconciler) updateStatus(ctx context.Context, instance *apiv1.WorkerVersion, workerRelease *apiv1.WorkerRelease) error {
+	instance.Status.ObservedGeneration = instance.Generation
+	instance.Status.WorkerReleaseName = workerRelease.Name
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionDegraded, metav1.ConditionFalse, "Released", "")
+	setCondition(&instance.Status.Conditions, instance.Generation, apiv1.ConditionReady, metav1.ConditionTrue, "Released", "")
+	return r.Status().Update(ctx, instance)
+}
+
+// finalize removes the script of a deleted WorkerVersion from the WorkerRelease
+// it shares with the other versions of the account.
+func (r *WorkerVersionReconciler) finalize(ctx context.Context, instance *apiv1.WorkerVersion) (ctrl.Result, error) {
+	logger := log.Log.WithValues("WorkerVersion", client.ObjectKeyFromObject(instance))
+
+	if !controllerutil.ContainsFinalizer(instance, workerVersionFinalizer) {
 		return ctrl.Result{}, nil
 	}
 
+	workerRelease := apiv1.WorkerRelease{}
+	err := r.Get(ctx, types.NamespacedName{Name: getWorkerRelease(instance.Spec.Accounts), Namespace: instance.GetNamespace()}, &workerRelease)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && workerRelease.Spec.WorkerVersions[instance.Spec.Scripts] == instance.Spec.Url {
+		delete(workerRelease.Spec.WorkerVersions, instance.Spec.Scripts)
+		delete(workerRelease.Spec.SecretRefs, instance.Spec.Scripts)
+		delete(workerRelease.Spec.Checksums, instance.Spec.Scripts)
+		delete(workerRelease.Spec.CompatibilityDates, instance.Spec.Scripts)
+		delete(workerRelease.Spec.MainModules, instance.Spec.Scripts)
+		if len(workerRelease.Spec.WorkerVersions) == 0 {
+			err = r.Delete(ctx, &workerRelease)
+		} else {
+			err = r.Update(ctx, &workerRelease)
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		logger.Info("script removed from WorkerRelease")
+	}
+
+	controllerutil.RemoveFinalizer(instance, workerVersionFinalizer)
+	err = r.Update(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
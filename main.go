@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -26,6 +27,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -52,11 +54,18 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var buildTimeout time.Duration
+	var configFile string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&buildTimeout, "build-timeout", 30*time.Minute,
+		"How long a JobBuilder build, retries included, may run before it is marked as failed.")
+	flag.StringVar(&configFile, "config", "",
+		"Path to the operator configuration file (storage, registry, images, ingress). "+
+			"Built-in defaults are used when empty.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,6 +74,12 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	operatorConfig, err := controllers.LoadOperatorConfig(configFile)
+	if err != nil {
+		setupLog.Error(err, "unable to load operator configuration", "config", configFile)
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -92,6 +107,7 @@ func main() {
 	if err = (&controllers.WorkerBundleReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+		Config: operatorConfig,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WorkerBundle")
 		os.Exit(1)
@@ -104,8 +120,12 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&controllers.JobBuilderReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Config:       operatorConfig,
+		BuildTimeout: buildTimeout,
+		Clientset:    kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		Recorder:     mgr.GetEventRecorderFor("jobbuilder-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "JobBuilder")
 		os.Exit(1)
@@ -113,10 +133,19 @@ func main() {
 	if err = (&controllers.WorkerAccountReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+		Config: operatorConfig,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WorkerAccount")
 		os.Exit(1)
 	}
+	if err = (&controllers.ReleaseRevisionReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Config: operatorConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ReleaseRevision")
+		os.Exit(1)
+	}
 	if err = (&controllers.WorkerDeploymentReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),